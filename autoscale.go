@@ -0,0 +1,314 @@
+package logger
+
+import (
+	"context"
+	"time"
+)
+
+// AutoscalePolicy lets CachedLogging add and retire workers, within
+// [Min, Max] per lane, by looking at the queues every Interval. At most one
+// worker is added or retired per lane and interval.
+type AutoscalePolicy struct {
+	Min int
+	Max int
+	// Interval defaults to one second
+	Interval time.Duration
+	// ScaleUpDepth adds a worker once the queues of the lane are filled
+	// above this ratio, 0.5 meaning half full
+	ScaleUpDepth float64
+	// ScaleDownDepth retires a worker once the queues are filled below it
+	ScaleDownDepth float64
+	// ScaleUpLatency adds a worker when writing a batch took longer on
+	// average, as long as records are waiting. Zero ignores the latency.
+	ScaleUpLatency time.Duration
+}
+
+var DefaultAutoscalePolicy = AutoscalePolicy{
+	Min:            1,
+	Max:            16,
+	Interval:       time.Second,
+	ScaleUpDepth:   0.5,
+	ScaleDownDepth: 0.05,
+}
+
+// flushSample is what the autoscaler last read from a worker, to compute
+// the latency of the batches written since.
+type flushSample struct {
+	nanos   uint64
+	flushes uint64
+}
+
+func (p AutoscalePolicy) normalize() AutoscalePolicy {
+	if p.Min < 1 {
+		p.Min = 1
+	}
+
+	if p.Max < p.Min {
+		p.Max = p.Min
+	}
+
+	if p.Interval <= 0 {
+		p.Interval = DefaultAutoscalePolicy.Interval
+	}
+
+	return p
+}
+
+// clamp keeps the initial worker count within the policy.
+func (p AutoscalePolicy) clamp(workers int) int {
+	if workers < p.Min {
+		return p.Min
+	}
+
+	if workers > p.Max {
+		return p.Max
+	}
+
+	return workers
+}
+
+func (l *CachedLogging[T]) autoscale(policy AutoscalePolicy, clock Clock) {
+	ticker := clock.NewTicker(policy.Interval)
+	defer ticker.Stop()
+
+	samples := make(map[*logWorker[T]]flushSample)
+
+	for {
+		select {
+		case <-l.stopping:
+			return
+		case <-ticker.C():
+		}
+
+		// The queues of a paused logger say nothing about the load
+		if l.paused.Load() {
+			continue
+		}
+
+		// Rebuilt every round, so retired workers are forgotten
+		next := make(map[*logWorker[T]]flushSample, len(samples))
+
+		for _, ln := range l.lanes {
+			l.autoscaleLane(ln, policy, samples, next)
+		}
+
+		samples = next
+	}
+}
+
+func (l *CachedLogging[T]) autoscaleLane(ln *lane[T], policy AutoscalePolicy, samples, next map[*logWorker[T]]flushSample) {
+	l.mu.RLock()
+	workers := ln.workers
+	l.mu.RUnlock()
+
+	var (
+		depth, capacity int
+		elapsed         flushSample
+	)
+
+	for _, w := range workers {
+		depth += w.queue.len()
+		capacity += w.queue.cap()
+
+		current := flushSample{nanos: w.stats.flushNanos.Load(), flushes: w.stats.flushes.Load()}
+		previous := samples[w]
+		elapsed.nanos += current.nanos - previous.nanos
+		elapsed.flushes += current.flushes - previous.flushes
+		next[w] = current
+	}
+
+	fill := 0.0
+	if capacity > 0 {
+		fill = float64(depth) / float64(capacity)
+	}
+
+	var latency time.Duration
+	if elapsed.flushes > 0 {
+		latency = time.Duration(elapsed.nanos / elapsed.flushes)
+	}
+
+	slow := policy.ScaleUpLatency > 0 && latency >= policy.ScaleUpLatency
+
+	switch {
+	case len(workers) < policy.Max && (fill >= policy.ScaleUpDepth || (slow && fill > policy.ScaleDownDepth)):
+		l.addWorker(ln)
+	case len(workers) > policy.Min && fill <= policy.ScaleDownDepth && !slow:
+		l.retireWorker(ln)
+	}
+}
+
+// rebalance prepares a change of the worker set, before l.mu is taken.
+// With a shard key, the keys move between workers, so the lane is blocked
+// and the records already queued are written first to keep their order;
+// settle unblocks it. The flush waits for the sink, under l.mu every
+// producer would wait along with it.
+func (l *CachedLogging[T]) rebalance(ln *lane[T]) bool {
+	if l.shardKey == nil {
+		return true
+	}
+
+	l.block(ln)
+
+	l.mu.RLock()
+	workers, closed := ln.workers, l.closed
+	l.mu.RUnlock()
+
+	// flushWorkers gives up once Shutdown starts
+	if closed || l.flushWorkers(context.Background(), workers) != nil {
+		l.unblock(ln)
+		return false
+	}
+
+	return true
+}
+
+// settle lets the producers of the lane in again after rebalance.
+func (l *CachedLogging[T]) settle(ln *lane[T]) {
+	if l.shardKey != nil {
+		l.unblock(ln)
+	}
+}
+
+// block keeps new producers out of the lane and waits for the ones routing
+// records in it.
+func (l *CachedLogging[T]) block(ln *lane[T]) {
+	blocked := make(chan struct{})
+	ln.blocked.Store(&blocked)
+
+	// They wait for queue space at most, which the workers make
+	for ln.producers.Load() > 0 {
+		time.Sleep(100 * time.Microsecond)
+	}
+}
+
+func (l *CachedLogging[T]) unblock(ln *lane[T]) {
+	if blocked := ln.blocked.Swap(nil); blocked != nil {
+		close(*blocked)
+	}
+}
+
+// enter counts the producer in the lane, waiting while a rebalance has it
+// blocked; leave counts it out. Only sharded loggers rebalance.
+func (l *CachedLogging[T]) enter(ctx context.Context, ln *lane[T]) error {
+	for {
+		ln.producers.Add(1)
+
+		blocked := ln.blocked.Load()
+		if blocked == nil {
+			return nil
+		}
+
+		ln.producers.Add(-1)
+
+		select {
+		case <-*blocked:
+		case <-l.stopping:
+			return ErrLoggerClosed
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+func (ln *lane[T]) leave() {
+	ln.producers.Add(-1)
+}
+
+func (l *CachedLogging[T]) addWorker(ln *lane[T]) {
+	if !l.rebalance(ln) {
+		return
+	}
+	defer l.settle(ln)
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if !l.closed {
+		l.startWorker(ln)
+	}
+}
+
+// retireWorker takes the last worker out of the lane, so no record is
+// routed to it anymore, and waits until it wrote what it still had queued.
+func (l *CachedLogging[T]) retireWorker(ln *lane[T]) {
+	// A lane always keeps a worker, the producers route to it
+	l.mu.RLock()
+	n := len(ln.workers)
+	l.mu.RUnlock()
+
+	if n <= 1 || !l.rebalance(ln) {
+		return
+	}
+
+	l.mu.Lock()
+
+	if l.closed || len(ln.workers) <= 1 {
+		l.mu.Unlock()
+		l.settle(ln)
+		return
+	}
+
+	n = len(ln.workers)
+	w := ln.workers[n-1]
+	ln.workers = ln.workers[: n-1 : n-1]
+	l.draining = append(l.draining, w)
+	l.mu.Unlock()
+	l.settle(ln)
+
+	// No producer picks w anymore, the ones that did push to its queue
+	// while it still runs
+	pushed := make(chan struct{})
+	go func() {
+		w.producers.Wait()
+		close(pushed)
+	}()
+
+	select {
+	case <-pushed:
+	case <-l.stopping:
+		// Shutdown drains it along with the others
+		return
+	}
+
+	close(w.retire)
+
+	select {
+	case <-w.done:
+	case <-l.stopping:
+		return
+	}
+
+	// Its retries ack the spool after the worker exited
+	if w.spool != nil {
+		select {
+		case <-w.spool.delivered():
+		case <-l.stopping:
+			return
+		}
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	for i, draining := range l.draining {
+		if draining == w {
+			l.draining = append(l.draining[:i:i], l.draining[i+1:]...)
+			break
+		}
+	}
+
+	l.retired.add(w.snapshot())
+
+	// Once closed, Shutdown owns the spools
+	if w.spool != nil && !l.closed {
+		for i, s := range l.spools {
+			if s == w.spool {
+				l.spools = append(l.spools[:i:i], l.spools[i+1:]...)
+				break
+			}
+		}
+
+		// Every record is delivered, sealing removes the segment
+		w.spool.close()
+	}
+}
@@ -0,0 +1,237 @@
+package logger
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestCachedLogging_Autoscale(t *testing.T) {
+	t.Parallel()
+	assert := require.New(t)
+
+	policy := AutoscalePolicy{Min: 1, Max: 3, ScaleUpDepth: 0.5, ScaleDownDepth: 0.1}
+	inner := &memoryLogger[int]{release: make(chan struct{})}
+	cached := NewCached[int](context.Background(), inner,
+		WithBufferSize(1),
+		WithQueueSize(4),
+		WithClock(newFakeClock()),
+		WithAutoscaling(policy),
+	)
+
+	ln := cached.lanes[0]
+	scale := func() int {
+		cached.autoscaleLane(ln, policy, map[*logWorker[int]]flushSample{}, map[*logWorker[int]]flushSample{})
+		return len(cached.Stats().Workers)
+	}
+
+	// The worker is stuck on the first record, its queue fills up
+	assert.NoError(cached.Log(0))
+	assert.Eventually(func() bool {
+		return ln.workers[0].queue.len() == 0
+	}, time.Second, time.Millisecond)
+
+	for i := 1; i <= 4; i++ {
+		assert.NoError(cached.Log(i))
+	}
+
+	assert.Equal(2, scale())
+	assert.Equal(3, scale())
+	assert.Equal(3, scale(), "never above Max")
+
+	close(inner.release)
+	assert.NoError(cached.Flush(context.Background()))
+
+	assert.Equal(2, scale())
+	assert.Equal(1, scale())
+	assert.Equal(1, scale(), "never below Min")
+
+	stats := cached.Stats()
+	assert.Empty(stats.Draining)
+	assert.EqualValues(5, stats.Total().Enqueued)
+	assert.EqualValues(5, stats.Total().Records)
+
+	assert.NoError(cached.Close())
+	assert.ElementsMatch([]int{0, 1, 2, 3, 4}, inner.records())
+}
+
+func TestCachedLogging_Autoscale_BlockedProducer(t *testing.T) {
+	t.Parallel()
+	assert := require.New(t)
+
+	policy := AutoscalePolicy{Min: 1, Max: 2, ScaleUpDepth: 0.5}
+	inner := &memoryLogger[int]{release: make(chan struct{})}
+	cached := NewCached[int](context.Background(), inner,
+		WithBufferSize(1),
+		WithQueueSize(2),
+		WithClock(newFakeClock()),
+		WithAutoscaling(policy),
+	)
+
+	ln := cached.lanes[0]
+
+	// The worker hangs on the first record, the queue fills up and the
+	// next producer waits for space
+	assert.NoError(cached.Log(0))
+	assert.Eventually(func() bool {
+		return ln.workers[0].queue.len() == 0
+	}, time.Second, time.Millisecond)
+	assert.NoError(cached.Log(1))
+	assert.NoError(cached.Log(2))
+
+	blocked := make(chan error, 1)
+	go func() {
+		blocked <- cached.Log(3)
+	}()
+
+	// Gives it time to block, the test holds either way
+	time.Sleep(10 * time.Millisecond)
+
+	// Adding a worker does not wait for the blocked producer
+	cached.autoscaleLane(ln, policy, map[*logWorker[int]]flushSample{}, map[*logWorker[int]]flushSample{})
+
+	start := time.Now()
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+
+	// Either worker, the deadline is kept
+	for i := 4; i < 6; i++ {
+		if err := cached.LogContext(ctx, i); err != nil {
+			assert.ErrorIs(err, context.DeadlineExceeded)
+		}
+	}
+
+	assert.Len(cached.Stats().Workers, 2)
+	assert.Less(time.Since(start), time.Second)
+
+	close(inner.release)
+	assert.NoError(<-blocked)
+	assert.NoError(cached.Close())
+}
+
+func TestCachedLogging_Autoscale_ClampsInitialWorkers(t *testing.T) {
+	t.Parallel()
+	assert := require.New(t)
+
+	cached := NewCached[int](context.Background(), &memoryLogger[int]{},
+		WithWorkerPool(10),
+		WithAutoscaling(AutoscalePolicy{Min: 2, Max: 4}),
+	)
+
+	assert.Len(cached.Stats().Workers, 4)
+	assert.NoError(cached.Close())
+}
+
+func TestCachedLogging_Autoscale_RoutingWhileScaling(t *testing.T) {
+	t.Parallel()
+
+	t.Run("round-robin", func(t *testing.T) {
+		t.Parallel()
+		assert := require.New(t)
+
+		const records = 4000
+
+		inner := &memoryLogger[int]{}
+		cached := NewCached[int](context.Background(), inner,
+			WithBufferSize(7),
+			WithQueueSize(8),
+			WithAutoscaling(AutoscalePolicy{Min: 1, Max: 4, Interval: time.Hour}),
+		)
+
+		stop := scaleContinuously(cached)
+
+		var wg sync.WaitGroup
+		for p := 0; p < 4; p++ {
+			wg.Add(1)
+			go func(p int) {
+				defer wg.Done()
+				for i := p; i < records; i += 4 {
+					assert.NoError(cached.Log(i))
+				}
+			}(p)
+		}
+
+		wg.Wait()
+		stop()
+		assert.NoError(cached.Close())
+		assert.Len(inner.records(), records)
+		assert.EqualValues(records, cached.Stats().Total().Enqueued)
+	})
+
+	t.Run("shard key", func(t *testing.T) {
+		t.Parallel()
+		assert := require.New(t)
+
+		const (
+			keys   = 8
+			perKey = 200
+		)
+
+		inner := &memoryLogger[keyedRecord]{}
+		cached := NewCached[keyedRecord](context.Background(), inner,
+			WithBufferSize(7),
+			WithShardKey(func(r keyedRecord) uint64 { return r.Key }),
+			WithAutoscaling(AutoscalePolicy{Min: 1, Max: 4, Interval: time.Hour}),
+		)
+
+		stop := scaleContinuously(cached)
+
+		var wg sync.WaitGroup
+		for key := uint64(0); key < keys; key++ {
+			wg.Add(1)
+			go func(key uint64) {
+				defer wg.Done()
+				for seq := 0; seq < perKey; seq++ {
+					assert.NoError(cached.Log(keyedRecord{Key: key, Seq: seq}))
+				}
+			}(key)
+		}
+
+		wg.Wait()
+		stop()
+		assert.NoError(cached.Close())
+
+		next := make(map[uint64]int, keys)
+		written := inner.records()
+		assert.Len(written, keys*perKey)
+
+		for _, r := range written {
+			assert.Equal(next[r.Key], r.Seq, "key %d out of order", r.Key)
+			next[r.Key]++
+		}
+	})
+}
+
+// scaleContinuously adds and retires workers until the returned function
+// is called.
+func scaleContinuously[T any](cached *CachedLogging[T]) func() {
+	done := make(chan struct{})
+	stopped := make(chan struct{})
+
+	go func() {
+		defer close(stopped)
+
+		ln := cached.lanes[0]
+		for i := 0; ; i++ {
+			select {
+			case <-done:
+				return
+			default:
+			}
+
+			if i%4 < 2 {
+				cached.addWorker(ln)
+			} else {
+				cached.retireWorker(ln)
+			}
+		}
+	}()
+
+	return func() {
+		close(done)
+		<-stopped
+	}
+}
@@ -0,0 +1,203 @@
+package logger
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+type BackpressurePolicy uint8
+
+const (
+	// BackpressureBlock waits until the worker has room for the record.
+	BackpressureBlock BackpressurePolicy = iota
+	// BackpressureDropNewest discards the record being logged.
+	BackpressureDropNewest
+	// BackpressureDropOldest discards the oldest queued record to make room.
+	BackpressureDropOldest
+	// BackpressureBlockTimeout waits up to the configured timeout and then
+	// discards the record being logged.
+	BackpressureBlockTimeout
+)
+
+var (
+	ErrRecordDropped = errors.New("logger: record dropped, worker queue is full")
+	// ErrBatchTooLarge is returned for an atomic batch that holds more
+	// records than the worker queue, it could never be queued.
+	ErrBatchTooLarge = errors.New("logger: batch is larger than the worker queue")
+)
+
+func (p BackpressurePolicy) String() string {
+	switch p {
+	case BackpressureBlock:
+		return "block"
+	case BackpressureDropNewest:
+		return "drop_newest"
+	case BackpressureDropOldest:
+		return "drop_oldest"
+	case BackpressureBlockTimeout:
+		return "block_timeout"
+	default:
+		return "unknown"
+	}
+}
+
+// enqueue hands the record to the worker queue according to the
+// backpressure policy of its lane.
+func (l *CachedLogging[T]) enqueue(ctx context.Context, ln *lane[T], w *logWorker[T], log T) error {
+	q := w.queue
+
+	// Fast path, the queue has room
+	if q.tryPush(log) {
+		return nil
+	}
+
+	switch ln.backpressure {
+	case BackpressureDropNewest:
+		l.drop(ln, w, 1)
+		return ErrRecordDropped
+	case BackpressureDropOldest:
+		var oldest [1]T
+
+		for !q.tryPush(log) {
+			if err := l.evicting(ctx); err != nil {
+				return err
+			}
+
+			// The worker may have emptied the queue in the meantime,
+			// so only count what was actually taken out
+			if q.popBatch(oldest[:]) > 0 {
+				l.drop(ln, w, 1)
+			}
+		}
+
+		return nil
+	case BackpressureBlockTimeout:
+		timer := time.NewTimer(ln.blockTimeout)
+		defer timer.Stop()
+
+		err := q.push(ctx, log, l.stopping, timer.C)
+		if errors.Is(err, ErrRecordDropped) {
+			l.drop(ln, w, 1)
+		}
+
+		return err
+	default:
+		return q.push(ctx, log, l.stopping, nil)
+	}
+}
+
+// enqueueBatch hands the records to one worker queue and returns the ones
+// that were queued, in order. Records dropped by the backpressure policy do
+// not stop the rest of the batch, unless batches are atomic.
+func (l *CachedLogging[T]) enqueueBatch(ctx context.Context, ln *lane[T], w *logWorker[T], records []T) ([]T, error) {
+	if l.atomicBatches {
+		if err := l.enqueueAll(ctx, ln, w, records); err != nil {
+			return nil, err
+		}
+
+		return records, nil
+	}
+
+	// Fast path, the queue has room for all of them
+	n := w.queue.tryPushBatch(records)
+	if n == len(records) {
+		return records, nil
+	}
+
+	// The capacity is capped, so append copies instead of writing
+	// into the caller's slice
+	queued := records[:n:n]
+
+	var dropped error
+
+	for _, log := range records[n:] {
+		err := l.enqueue(ctx, ln, w, log)
+
+		switch {
+		case err == nil:
+			queued = append(queued, log)
+		case errors.Is(err, ErrRecordDropped):
+			dropped = err
+		default:
+			return queued, err
+		}
+	}
+
+	return queued, dropped
+}
+
+// enqueueAll queues every record or none of them. The backpressure policy
+// applies to the batch as a whole.
+func (l *CachedLogging[T]) enqueueAll(ctx context.Context, ln *lane[T], w *logWorker[T], records []T) error {
+	q := w.queue.(batchQueue[T])
+
+	if len(records) > w.queue.cap() {
+		return ErrBatchTooLarge
+	}
+
+	if q.tryPushAll(records) {
+		return nil
+	}
+
+	switch ln.backpressure {
+	case BackpressureDropNewest:
+		l.drop(ln, w, len(records))
+		return ErrRecordDropped
+	case BackpressureDropOldest:
+		var oldest []T
+
+		for !q.tryPushAll(records) {
+			if err := l.evicting(ctx); err != nil {
+				return err
+			}
+
+			// Evict only what the batch is missing
+			missing := len(records) - (w.queue.cap() - w.queue.len())
+			if missing <= 0 {
+				continue
+			}
+
+			if oldest == nil {
+				oldest = make([]T, len(records))
+			}
+
+			if n := w.queue.popBatch(oldest[:missing]); n > 0 {
+				l.drop(ln, w, n)
+			}
+		}
+
+		return nil
+	case BackpressureBlockTimeout:
+		timer := time.NewTimer(ln.blockTimeout)
+		defer timer.Stop()
+
+		err := q.pushAll(ctx, records, l.stopping, timer.C)
+		if errors.Is(err, ErrRecordDropped) {
+			l.drop(ln, w, len(records))
+		}
+
+		return err
+	default:
+		return q.pushAll(ctx, records, l.stopping, nil)
+	}
+}
+
+// evicting stops a DropOldest loop once ctx is done or the logger closes,
+// the producers racing for the freed slots could keep it going.
+func (l *CachedLogging[T]) evicting(ctx context.Context) error {
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-l.stopping:
+		return ErrLoggerClosed
+	default:
+		return nil
+	}
+}
+
+func (l *CachedLogging[T]) drop(ln *lane[T], w *logWorker[T], n int) {
+	w.stats.dropped.Add(uint64(n))
+	dropped := l.dropped.Add(uint64(n))
+	l.error.Print(recordDropped, ln.backpressure, ln.priority, w.id, dropped)
+}
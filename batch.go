@@ -0,0 +1,82 @@
+package logger
+
+import "github.com/nano-interactive/go-logger/serializer"
+
+// batchLimit bounds a batch by record count, estimated bytes or both.
+// A zero limit is not enforced.
+type batchLimit[T any] struct {
+	count int
+	bytes int
+	size  func(T) int
+}
+
+// sizeOf returns the estimated size of the record, zero without a byte limit.
+// A record counts as one byte at least, so the byte limit always bounds the
+// batch.
+func (b batchLimit[T]) sizeOf(data T) int {
+	if b.bytes <= 0 || b.size == nil {
+		return 0
+	}
+
+	if n := b.size(data); n > 0 {
+		return n
+	}
+
+	return 1
+}
+
+// fits reports whether a record of n bytes can join a batch of count
+// records and bytes bytes. A record larger than the byte limit still goes
+// out, alone.
+func (b batchLimit[T]) fits(count, bytes, n int) bool {
+	if count == 0 {
+		return true
+	}
+
+	if b.count > 0 && count >= b.count {
+		return false
+	}
+
+	return b.bytes <= 0 || bytes+n <= b.bytes
+}
+
+// full reports whether the batch reached one of the limits.
+func (b batchLimit[T]) full(count, bytes int) bool {
+	return (b.count > 0 && count >= b.count) || (b.bytes > 0 && bytes >= b.bytes)
+}
+
+// split cuts records into batches within the limits.
+func (b batchLimit[T]) split(records []T) [][]T {
+	batches := make([][]T, 0, 1)
+	start, bytes := 0, 0
+
+	for i, data := range records {
+		n := b.sizeOf(data)
+
+		if !b.fits(i-start, bytes, n) {
+			batches = append(batches, records[start:i])
+			start, bytes = i, 0
+		}
+
+		bytes += n
+	}
+
+	if start < len(records) {
+		batches = append(batches, records[start:])
+	}
+
+	return batches
+}
+
+// SerializedSize estimates the record size by serializing it, exact but as
+// expensive as the write itself. s must be safe for concurrent use.
+func SerializedSize[T any](s serializer.Interface[T]) func(T) int {
+	return func(data T) int {
+		raw, err := s.Serialize([]T{data})
+		if err != nil {
+			return 0
+		}
+
+		return len(raw)
+	}
+}
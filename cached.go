@@ -2,28 +2,99 @@ package logger
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"io"
 	"sync"
 	"sync/atomic"
+
+	"github.com/nano-interactive/go-logger/serializer"
 )
 
 var (
-	_ io.Closer = &CachedLogging[any]{}
-	_ Log[any]  = &CachedLogging[any]{}
+	_ io.Closer       = &CachedLogging[any]{}
+	_ Log[any]        = &CachedLogging[any]{}
+	_ ContextLog[any] = &CachedLogging[any]{}
 )
 
+var ErrLoggerClosed = errors.New("logger: cached logger is closed")
+
+// ShutdownError is returned by Shutdown when its context was done before
+// every record was written.
+type ShutdownError struct {
+	// Undelivered is the number of records not known to have reached the
+	// inner logger: those taken back from the queues and the retries, and
+	// the InFlight ones
+	Undelivered int
+	// InFlight is how many of them the workers and the retries still had
+	// in memory, being batched, held by Pause or written to a hung sink.
+	// They can not be taken back and may still reach the inner logger
+	// after Shutdown returned, if its write does return.
+	InFlight int
+	// Fallback is how many of the others the fallback logger accepted
+	Fallback int
+	Err      error
+}
+
+func (e *ShutdownError) Error() string {
+	return fmt.Sprintf("logger: shutdown interrupted (%v), %d records undelivered, %d written to the fallback logger", e.Err, e.Undelivered, e.Fallback)
+}
+
+func (e *ShutdownError) Unwrap() error {
+	return e.Err
+}
+
 type CachedLogging[T any] struct {
-	cancel context.CancelFunc
-	logger Log[T]
-	chs    []chan T
-	chsLen uint64
-	idx    uint64
-	wg     *sync.WaitGroup
+	// mu is held for reading by every Log call while it picks its workers
+	// and for writing by Close, so no record is queued once the workers
+	// drain. The push itself is counted by logWorker.producers instead, it
+	// may wait for queue space
+	mu          sync.RWMutex
+	closed      bool
+	stopping    chan struct{}
+	stopWorkers context.CancelFunc
+	closeOnce   sync.Once
+	closeErr    error
+	logger      Log[T]
+	error       Error
+	enrich      Enricher[T]
+	shardKey    func(T) uint64
+	classify    func(T) Priority
+	lanes       []*lane[T]
+	// atomicBatches queues each part of a LogMultiple batch whole or not at all
+	atomicBatches bool
+	retries       *retryQueue[T]
+	fallback      Log[T]
+	codec         serializer.Codec[T]
+	wg            *sync.WaitGroup
+	dropped       atomic.Uint64
+	// paused is set under reconfigMu, see Pause
+	paused  atomic.Bool
+	latency *latencyHistogram
+
+	// spawn builds a worker, startWorker runs it on workerCtx. The fields
+	// below change with the worker set, under mu.
+	spawn      func(id, queueSize int) *logWorker[T]
+	workerCtx  context.Context
+	nextWorker int
+	spools     []*spool
+	// draining are the workers removed from their lane that still empty
+	// their queue, retired sums the counters of the ones that are gone
+	draining []*logWorker[T]
+	retired  WorkerStats
+
+	// reconfigMu serializes Reconfigure. limit and poolSize are the values
+	// it changes, under mu; autoscaled lanes ignore poolSize.
+	reconfigMu sync.Mutex
+	limit      batchLimit[T]
+	poolSize   int
+	autoscaled bool
 }
 
+// NewCached starts the workers. They run until Close is called or ctx is
+// done, whichever comes first; both drain the queued records.
 func NewCached[T any](ctx context.Context, log Log[T], mods ...ModifierCached) *CachedLogging[T] {
 	config := defaultCachedConfig
-	ctx, cancel := context.WithCancel(ctx)
 
 	for _, mod := range mods {
 		mod(&config)
@@ -33,60 +104,513 @@ func NewCached[T any](ctx context.Context, log Log[T], mods ...ModifierCached) *
 		config.retryCount = 1
 	}
 
-	chs := make([]chan T, config.workers)
-	cancelFns := make([]context.CancelFunc, 0, config.workers)
+	if config.queueSize < 0 {
+		config.queueSize = defaultCachedConfig.queueSize
+	}
+
+	// DropOldest makes room by taking a record out, an unbuffered queue
+	// never has one
+	if config.queueSize == 0 {
+		config.queueSize = 1
+	}
+
+	if config.logger == nil {
+		config.logger = nopErrorLog
+	}
+
+	if config.clock == nil {
+		config.clock = systemClock{}
+	}
+
+	if config.retryConcurrency <= 0 {
+		config.retryConcurrency = 1
+	}
+
+	size := cachedOption[func(T) int](config.logger, "batch size", config.batchSize)
+
+	// Without a byte limit, the record count is the only bound. A byte
+	// limit whose size function was ignored bounds nothing
+	if config.bufferSize <= 0 && (config.maxBatchBytes <= 0 || size == nil) {
+		config.bufferSize = defaultBufferSize
+	}
+
+	limit := batchLimit[T]{
+		count: config.bufferSize,
+		bytes: config.maxBatchBytes,
+		size:  size,
+	}
+
+	classify := cachedOption[func(T) Priority](config.logger, "priority", config.classify)
+	lanes := newLanes[T](&config, classify != nil)
 	wg := &sync.WaitGroup{}
-	wg.Add(config.workers)
 
-	for i := 0; i < config.workers; i++ {
-		chs[i] = make(chan T, 4096)
-		workerCtx, cancel := context.WithCancel(ctx)
-		cancelFns = append(cancelFns, cancel)
-		go logWorker(workerCtx, wg, log, chs[i], config.bufferSize, config.retryCount)
+	retries := newRetryQueue(
+		log,
+		cachedOption[Log[T]](config.logger, "dead-letter", config.deadLetter),
+		config.logger,
+		config.clock,
+		config.retryPolicy,
+		config.retryCount,
+		config.retryConcurrency,
+	)
+
+	codec := cachedOption[serializer.Codec[T]](config.logger, "spool", config.spoolCodec)
+	newSpool := openSpools[T](&config, codec, log, limit, wg)
+
+	for _, ln := range lanes {
+		if newSpool != nil && ln.backpressure == BackpressureDropOldest {
+			ln.backpressure = BackpressureDropNewest
+		}
+	}
+
+	shardKey := cachedOption[func(T) uint64](config.logger, "shard key", config.shardKey)
+
+	// The workers only stop when Close tells them to, after the last
+	// producer is gone; the caller's ctx goes through Close as well
+	workerCtx, stopWorkers := context.WithCancel(context.Background())
+	latency := newLatencyHistogram()
+
+	spawn := func(id, queueSize int) *logWorker[T] {
+		worker := &logWorker[T]{
+			id:            id,
+			log:           log,
+			error:         config.logger,
+			retries:       retries,
+			ordered:       shardKey != nil,
+			queue:         newQueue[T](queueSize, config.ringBuffer),
+			flushes:       make(chan chan struct{}),
+			limits:        make(chan batchLimit[T]),
+			pauses:        make(chan bool),
+			pauseLimit:    config.pauseLimit,
+			retire:        make(chan struct{}),
+			done:          make(chan struct{}),
+			clock:         config.clock,
+			flushInterval: config.flushInterval,
+			latency:       latency,
+		}
+
+		if newSpool != nil {
+			worker.spool = newSpool(id)
+		}
+
+		return worker
+	}
+
+	l := &CachedLogging[T]{
+		stopping:      make(chan struct{}),
+		stopWorkers:   stopWorkers,
+		logger:        log,
+		error:         config.logger,
+		enrich:        cachedOption[Enricher[T]](config.logger, "enricher", config.enrich),
+		shardKey:      shardKey,
+		classify:      classify,
+		lanes:         lanes,
+		atomicBatches: config.atomicBatches,
+		retries:       retries,
+		fallback:      cachedOption[Log[T]](config.logger, "shutdown fallback", config.fallback),
+		codec:         codec,
+		wg:            wg,
+		latency:       latency,
+		spawn:         spawn,
+		workerCtx:     workerCtx,
+		limit:         limit,
+		poolSize:      config.workers,
+		autoscaled:    config.autoscale != nil,
+	}
+
+	for _, ln := range lanes {
+		if config.autoscale != nil {
+			ln.initial = config.autoscale.clamp(ln.initial)
+		}
+
+		for i := 0; i < ln.initial; i++ {
+			l.startWorker(ln)
+		}
+	}
+
+	if config.autoscale != nil {
+		go l.autoscale(*config.autoscale, config.clock)
 	}
 
-	go func(ctx context.Context) {
-		<-ctx.Done()
+	if config.expvarName != "" {
+		l.publishExpvar(config.expvarName)
+	}
 
-		for i := 0; i < config.workers; i++ {
-			cancel := cancelFns[i]
-			close(chs[i])
-			cancel()
+	go func() {
+		select {
+		case <-ctx.Done():
+			_ = l.Close()
+		case <-l.stopping:
 		}
-	}(ctx)
+	}()
+
+	return l
+}
+
+// startWorker adds a worker to the lane. The caller holds l.mu, or is
+// NewCached.
+func (l *CachedLogging[T]) startWorker(ln *lane[T]) {
+	worker := l.spawn(l.nextWorker, ln.queueSize)
+	worker.limit = l.limit
+	worker.paused = l.paused.Load()
+	worker.dropped = &l.dropped
+	worker.priority = ln.priority
+	l.nextWorker++
+
+	if worker.spool != nil {
+		l.spools = append(l.spools, worker.spool)
+	}
+
+	// Copied, Stats and Flush may still hold the previous slice
+	workers := make([]*logWorker[T], 0, len(ln.workers)+1)
+	ln.workers = append(append(workers, ln.workers...), worker)
+
+	l.wg.Add(1)
+	go worker.supervise(l.workerCtx, l.wg)
+}
 
-	return &CachedLogging[T]{
-		logger: log,
-		chs:    chs,
-		chsLen: uint64(len(chs)),
-		cancel: cancel,
-		wg:     wg,
+// workers returns every worker that may still hold records, in lane order.
+func (l *CachedLogging[T]) workers() []*logWorker[T] {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+
+	workers := make([]*logWorker[T], 0, len(l.draining))
+	for _, ln := range l.lanes {
+		workers = append(workers, ln.workers...)
 	}
+
+	return append(workers, l.draining...)
 }
 
 func (l *CachedLogging[T]) Log(log T) error {
-	idx := atomic.AddUint64(&l.idx, 1) % l.chsLen
+	return l.LogContext(context.Background(), log)
+}
+
+func (l *CachedLogging[T]) LogMultiple(logs []T) error {
+	return l.LogMultipleContext(context.Background(), logs)
+}
+
+// LogContext queues the record, waiting for space in the worker channel at
+// most until ctx is done.
+func (l *CachedLogging[T]) LogContext(ctx context.Context, log T) error {
+	if l.enrich != nil {
+		log = l.enrich(ctx, log)
+	}
+
+	ln := l.laneOf(log)
+
+	if l.shardKey != nil {
+		if err := l.enter(ctx, ln); err != nil {
+			return err
+		}
+		defer ln.leave()
+	}
+
+	l.mu.RLock()
+
+	if l.closed {
+		l.mu.RUnlock()
+		return ErrLoggerClosed
+	}
+
+	// The push may wait for queue space, l.mu is not held meanwhile
+	w := l.worker(ln, log, atomic.AddUint64(&ln.idx, 1))
+	w.producers.Add(1)
+	l.mu.RUnlock()
+
+	defer w.producers.Done()
+
+	return l.enqueueSpooled(ctx, ln, w, log)
+}
+
+// LogMultipleContext queues the batch on a single worker of its lane, so
+// the records are written together and in order. With a shard key or
+// several lanes, the batch is split by the worker each record goes to.
+//
+// When ctx is done part way, the records queued so far stay queued and
+// ctx.Err() is returned. Records dropped by the backpressure policy do not
+// stop the rest of the batch. With WithAtomicBatches, each part is queued
+// whole or not at all.
+func (l *CachedLogging[T]) LogMultipleContext(ctx context.Context, logs []T) error {
+	var dropped error
+
+	if l.shardKey != nil {
+		for _, ln := range l.lanes {
+			if err := l.enter(ctx, ln); err != nil {
+				return err
+			}
+			defer ln.leave()
+		}
+	}
+
+	l.mu.RLock()
+
+	if l.closed {
+		l.mu.RUnlock()
+		return ErrLoggerClosed
+	}
+
+	batches := l.split(ctx, logs)
+	for _, batch := range batches {
+		batch.worker.producers.Add(1)
+	}
+	l.mu.RUnlock()
+
+	defer func() {
+		for _, batch := range batches {
+			batch.worker.producers.Done()
+		}
+	}()
+
+	for _, batch := range batches {
+		err := l.enqueueBatchSpooled(ctx, batch.lane, batch.worker, batch.records)
+
+		switch {
+		case err == nil:
+		case errors.Is(err, ErrRecordDropped):
+			dropped = err
+		default:
+			return err
+		}
+	}
+
+	return dropped
+}
+
+// workerBatch is the part of a LogMultiple batch going to one worker.
+type workerBatch[T any] struct {
+	lane    *lane[T]
+	worker  *logWorker[T]
+	records []T
+}
+
+// split groups the records by the worker they go to, keeping their order.
+// Without a shard key, the batch takes one worker per lane, round robin.
+func (l *CachedLogging[T]) split(ctx context.Context, logs []T) []workerBatch[T] {
+	// The common case needs neither a copy nor grouping
+	if l.enrich == nil && l.shardKey == nil && len(l.lanes) == 1 {
+		ln := l.lanes[0]
+		if len(logs) == 0 {
+			return nil
+		}
+
+		return []workerBatch[T]{{
+			lane:    ln,
+			worker:  l.worker(ln, logs[0], atomic.AddUint64(&ln.idx, 1)),
+			records: logs,
+		}}
+	}
+
+	batches := make([]workerBatch[T], 0, 1)
+
+	for _, log := range logs {
+		if l.enrich != nil {
+			log = l.enrich(ctx, log)
+		}
+
+		ln := l.laneOf(log)
+
+		var w *logWorker[T]
+		if l.shardKey != nil {
+			w = l.worker(ln, log, 0)
+		}
+
+		i := 0
+		for ; i < len(batches); i++ {
+			if batches[i].lane == ln && (w == nil || batches[i].worker == w) {
+				break
+			}
+		}
+
+		if i == len(batches) {
+			if w == nil {
+				w = l.worker(ln, log, atomic.AddUint64(&ln.idx, 1))
+			}
+
+			batches = append(batches, workerBatch[T]{lane: ln, worker: w})
+		}
+
+		batches[i].records = append(batches[i].records, log)
+	}
+
+	return batches
+}
+
+// shard maps the key to a worker. The keys go through the MurmurHash3
+// finalizer first, so sequential ids spread over all workers.
+func shard(key, workers uint64) uint64 {
+	key ^= key >> 33
+	key *= 0xff51afd7ed558ccd
+	key ^= key >> 33
+	key *= 0xc4ceb9fe1a85ec53
+	key ^= key >> 33
+
+	return key % workers
+}
+
+// Dropped returns the number of records discarded by the backpressure policy.
+func (l *CachedLogging[T]) Dropped() uint64 {
+	return l.dropped.Load()
+}
+
+// Restarts returns how many times a worker was restarted after a panic.
+func (l *CachedLogging[T]) Restarts() uint64 {
+	return l.Stats().Total().Restarts
+}
+
+// Flush writes every record queued before the call to the inner logger and
+// waits until its LogMultiple calls, retries included, have returned. When
+// the inner logger implements Sync, it is synced afterwards. The returned
+// error is either ctx.Err(), ErrLoggerClosed, ErrPaused, the last batch that
+// could not be delivered since the previous Flush, or the Sync error.
+func (l *CachedLogging[T]) Flush(ctx context.Context) error {
+	if l.paused.Load() {
+		return ErrPaused
+	}
+
+	// The lanes are in priority order, the high one is written first
+	for _, ln := range l.lanes {
+		if err := l.flushLane(ctx, ln); err != nil {
+			return err
+		}
+	}
+
+	retried := make(chan struct{})
+	go func() {
+		l.retries.wait()
+		close(retried)
+	}()
+
+	select {
+	case <-retried:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+
+	if err := l.retries.takeErr(); err != nil {
+		return err
+	}
+
+	if s, ok := l.logger.(syncer); ok {
+		return s.Sync()
+	}
 
-	l.chs[idx] <- log
 	return nil
 }
 
-func (l *CachedLogging[T]) LogMultiple(logs []T) error {
-	length := uint64(len(logs))
+func (l *CachedLogging[T]) flushLane(ctx context.Context, ln *lane[T]) error {
+	l.mu.RLock()
+	workers := append([]*logWorker[T](nil), ln.workers...)
+
+	// A retiring worker may still hold records of the lane
+	for _, w := range l.draining {
+		if w.priority == ln.priority {
+			workers = append(workers, w)
+		}
+	}
+	l.mu.RUnlock()
+
+	return l.flushWorkers(ctx, workers)
+}
+
+func (l *CachedLogging[T]) flushWorkers(ctx context.Context, workers []*logWorker[T]) error {
+	replies := make([]chan struct{}, 0, len(workers))
+
+	for _, worker := range workers {
+		reply := make(chan struct{}, 1)
+
+		select {
+		case worker.flushes <- reply:
+			replies = append(replies, reply)
+		case <-worker.done:
+			// A retired worker wrote everything before it exited, unless
+			// all of them exited on Close
+			select {
+			case <-l.stopping:
+				return ErrLoggerClosed
+			default:
+			}
+		case <-l.stopping:
+			// Close drains and writes everything on its own
+			return ErrLoggerClosed
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
 
-	for i := uint64(0); i < length; i++ {
-		// Evenly distribute the logs to the workers
-		l.chs[i%l.chsLen] <- logs[i]
+	for _, reply := range replies {
+		select {
+		case <-reply:
+		case <-l.stopping:
+			return ErrLoggerClosed
+		case <-ctx.Done():
+			return ctx.Err()
+		}
 	}
 
 	return nil
 }
 
+// Close stops accepting records, writes everything queued to the inner
+// logger and closes it. It is Shutdown without a deadline.
 func (l *CachedLogging[T]) Close() error {
-	c := l.cancel
-	c()
+	return l.Shutdown(context.Background())
+}
+
+// Shutdown stops accepting records and writes everything queued to the
+// inner logger until ctx is done. Log calls blocked on a full queue return
+// ErrLoggerClosed, as does every Log after Shutdown.
+//
+// When ctx is done first, the records still queued and the batches waiting
+// for a retry are handed to the fallback logger (WithShutdownFallback) and
+// a *ShutdownError reports how many records were not delivered. Batches in
+// the middle of a write to a hung sink, and the records a worker batches or
+// holds, can not be taken back; they are counted as InFlight. The inner
+// logger is not closed in that case, since the workers may still use it.
+//
+// Shutdown and Close are safe to call more than once; later calls wait for
+// the first and return its result.
+func (l *CachedLogging[T]) Shutdown(ctx context.Context) error {
+	l.closeOnce.Do(func() {
+		l.closeErr = l.shutdown(ctx)
+	})
+
+	return l.closeErr
+}
+
+func (l *CachedLogging[T]) shutdown(ctx context.Context) error {
+	// 1. Release the producers blocked on a full queue
+	close(l.stopping)
+
+	// 2. Reject the new Log calls and wait for the in-flight ones, the
+	// ones blocked on a full queue were released by stopping
+	l.mu.Lock()
+	l.closed = true
+	l.mu.Unlock()
+
+	for _, w := range l.workers() {
+		w.producers.Wait()
+	}
+
+	// 3. Nothing is queued anymore, the workers drain and exit
+	l.stopWorkers()
 
-	l.wg.Wait()
+	finished := make(chan struct{})
+	go func() {
+		l.wg.Wait()
+		l.retries.wait()
+		close(finished)
+	}()
+
+	select {
+	case <-finished:
+	case <-ctx.Done():
+		return l.abandon(ctx.Err())
+	}
+
+	for _, s := range l.spools {
+		s.close()
+	}
 
 	if logger, ok := l.logger.(io.Closer); ok {
 		return logger.Close()
@@ -94,3 +618,45 @@ func (l *CachedLogging[T]) Close() error {
 
 	return nil
 }
+
+// abandon collects what the workers could not write before the deadline
+// and hands it to the fallback logger.
+func (l *CachedLogging[T]) abandon(cause error) error {
+	// The spool keeps everything for the next start, late acks from the
+	// workers must not truncate it anymore
+	for _, s := range l.spools {
+		s.close()
+	}
+
+	records := make([]T, 0)
+
+	batches, inFlight := l.retries.abort()
+	for _, batch := range batches {
+		records = append(records, batch...)
+	}
+
+	// Racing the workers here is fine, whatever is taken was not written
+	buf := make([]T, receiveBatch)
+
+	for _, worker := range l.workers() {
+		for n := worker.queue.popBatch(buf); n > 0; n = worker.queue.popBatch(buf) {
+			records = append(records, buf[:n]...)
+		}
+
+		inFlight += int(worker.stats.buffered.Load())
+	}
+
+	err := &ShutdownError{Undelivered: len(records) + inFlight, InFlight: inFlight, Err: cause}
+
+	if l.fallback != nil && len(records) > 0 {
+		if fallbackErr := l.fallback.LogMultiple(records); fallbackErr != nil {
+			l.error.Print(failedToWriteFallback, len(records), fallbackErr)
+		} else {
+			err.Fallback = len(records)
+		}
+	}
+
+	l.error.Print(shutdownUndelivered, err.Undelivered, err.Fallback, cause)
+
+	return err
+}
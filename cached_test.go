@@ -1,5 +1,573 @@
 package logger
 
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/nano-interactive/go-logger/__mocks__/error_log"
+)
+
+type ctxKey struct{}
+
+// memoryLogger collects every batch it receives, optionally blocking until
+// release is closed.
+type memoryLogger[T any] struct {
+	mu      sync.Mutex
+	batches [][]T
+	release chan struct{}
+	err     error
+	syncs   int
+}
+
+func (m *memoryLogger[T]) Log(data T) error {
+	return m.LogMultiple([]T{data})
+}
+
+func (m *memoryLogger[T]) LogMultiple(data []T) error {
+	if m.release != nil {
+		<-m.release
+	}
+
+	if m.err != nil {
+		return m.err
+	}
+
+	batch := make([]T, len(data))
+	copy(batch, data)
+
+	m.mu.Lock()
+	m.batches = append(m.batches, batch)
+	m.mu.Unlock()
+
+	return nil
+}
+
+func (m *memoryLogger[T]) Sync() error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.syncs++
+	return nil
+}
+
+func (m *memoryLogger[T]) records() []T {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	records := make([]T, 0)
+	for _, batch := range m.batches {
+		records = append(records, batch...)
+	}
+
+	return records
+}
+
+func TestCachedLogging_LogContext_Enricher(t *testing.T) {
+	t.Parallel()
+	assert := require.New(t)
+
+	inner := &memoryLogger[string]{}
+	cached := NewCached[string](context.Background(), inner,
+		WithBufferSize(10),
+		WithCachedEnricher(func(ctx context.Context, data string) string {
+			if requestID, ok := ctx.Value(ctxKey{}).(string); ok {
+				return requestID + ":" + data
+			}
+
+			return data
+		}),
+	)
+
+	ctx := context.WithValue(context.Background(), ctxKey{}, "req-1")
+
+	assert.NoError(cached.LogContext(ctx, "first"))
+	assert.NoError(cached.LogMultipleContext(ctx, []string{"second", "third"}))
+	assert.NoError(cached.Log("plain"))
+	assert.NoError(cached.Close())
+
+	assert.ElementsMatch([]string{"req-1:first", "req-1:second", "req-1:third", "plain"}, inner.records())
+}
+
+func TestCachedLogging_LogContext_Deadline(t *testing.T) {
+	t.Parallel()
+	assert := require.New(t)
+
+	inner := &memoryLogger[int]{release: make(chan struct{})}
+	cached := NewCached[int](context.Background(), inner, WithBufferSize(2))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	var err error
+	for i := 0; err == nil; i++ {
+		err = cached.LogContext(ctx, i)
+	}
+
+	assert.ErrorIs(err, context.DeadlineExceeded)
+
+	close(inner.release)
+	assert.NoError(cached.Close())
+}
+
+func TestCachedLogging_LogMultipleContext_Canceled(t *testing.T) {
+	t.Parallel()
+	assert := require.New(t)
+
+	inner := &memoryLogger[int]{release: make(chan struct{})}
+	cached := NewCached[int](context.Background(), inner, WithBufferSize(2))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(20*time.Millisecond, cancel)
+
+	assert.ErrorIs(cached.LogMultipleContext(ctx, make([]int, 10_000)), context.Canceled)
+
+	close(inner.release)
+	assert.NoError(cached.Close())
+}
+
+func TestCachedLogging_Backpressure_DropNewest(t *testing.T) {
+	t.Parallel()
+	assert := require.New(t)
+
+	errLog := error_log.NewMockLogger()
+	inner := &memoryLogger[int]{release: make(chan struct{})}
+	cached := NewCached[int](context.Background(), inner,
+		WithBufferSize(2),
+		WithQueueSize(4),
+		WithBackpressure(BackpressureDropNewest),
+		WithCachedErrorLogger(errLog),
+	)
+
+	var (
+		err    error
+		logged int
+	)
+
+	for ; err == nil; logged++ {
+		err = cached.Log(logged)
+	}
+
+	assert.ErrorIs(err, ErrRecordDropped)
+	assert.EqualValues(1, cached.Dropped())
+	assert.Equal([]string{fmt.Sprintf(recordDropped, "drop_newest", "normal", 0, 1)}, errLog.Buffer)
+
+	close(inner.release)
+	assert.NoError(cached.Close())
+	assert.Len(inner.records(), logged-1)
+	assert.NotContains(inner.records(), logged-1)
+}
+
+func TestCachedLogging_Backpressure_DropOldest(t *testing.T) {
+	t.Parallel()
+	assert := require.New(t)
+
+	inner := &memoryLogger[int]{release: make(chan struct{})}
+	cached := NewCached[int](context.Background(), inner,
+		WithBufferSize(2),
+		WithQueueSize(4),
+		WithBackpressure(BackpressureDropOldest),
+	)
+
+	for i := 0; i < 100; i++ {
+		assert.NoError(cached.Log(i))
+	}
+
+	close(inner.release)
+	assert.NoError(cached.Close())
+
+	records := inner.records()
+	assert.Contains(records, 99)
+	assert.EqualValues(100, uint64(len(records))+cached.Dropped())
+}
+
+func TestCachedLogging_Backpressure_DropOldest_Unbuffered(t *testing.T) {
+	t.Parallel()
+	assert := require.New(t)
+
+	inner := &memoryLogger[int]{release: make(chan struct{})}
+	cached := NewCached[int](context.Background(), inner,
+		WithBufferSize(1),
+		WithQueueSize(0),
+		WithBackpressure(BackpressureDropOldest),
+	)
+
+	w := cached.lanes[0].workers[0]
+	assert.Equal(1, w.queue.cap())
+
+	// The worker hangs on the first record, the second fills the queue
+	assert.NoError(cached.Log(0))
+	assert.Eventually(func() bool {
+		return w.queue.len() == 0
+	}, time.Second, time.Millisecond)
+	assert.NoError(cached.Log(1))
+
+	// The third evicts the second
+	assert.NoError(cached.Log(2))
+	assert.EqualValues(1, cached.Dropped())
+
+	// Once ctx is done, nothing is evicted anymore
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	assert.ErrorIs(cached.LogContext(ctx, 3), context.Canceled)
+	assert.EqualValues(1, cached.Dropped())
+
+	close(inner.release)
+	assert.NoError(cached.Close())
+	assert.Equal([]int{0, 2}, inner.records())
+}
+
+func TestCachedLogging_Backpressure_BlockTimeout(t *testing.T) {
+	t.Parallel()
+	assert := require.New(t)
+
+	inner := &memoryLogger[int]{release: make(chan struct{})}
+	cached := NewCached[int](context.Background(), inner,
+		WithBufferSize(2),
+		WithQueueSize(4),
+		WithBlockTimeout(10*time.Millisecond),
+	)
+
+	var err error
+	for i := 0; err == nil; i++ {
+		err = cached.Log(i)
+	}
+
+	assert.ErrorIs(err, ErrRecordDropped)
+	assert.EqualValues(1, cached.Dropped())
+
+	close(inner.release)
+	assert.NoError(cached.Close())
+}
+
+func TestCachedLogging_Flush(t *testing.T) {
+	t.Parallel()
+	assert := require.New(t)
+
+	inner := &memoryLogger[int]{}
+	cached := NewCached[int](context.Background(), inner, WithBufferSize(100), WithWorkerPool(3))
+
+	assert.NoError(cached.LogMultiple([]int{1, 2, 3, 4, 5}))
+	assert.NoError(cached.Flush(context.Background()))
+
+	assert.ElementsMatch([]int{1, 2, 3, 4, 5}, inner.records())
+	assert.Equal(1, inner.syncs)
+
+	// The logger keeps working after a flush
+	assert.NoError(cached.Log(6))
+	assert.NoError(cached.Close())
+	assert.ElementsMatch([]int{1, 2, 3, 4, 5, 6}, inner.records())
+}
+
+func TestCachedLogging_Flush_Deadline(t *testing.T) {
+	t.Parallel()
+	assert := require.New(t)
+
+	inner := &memoryLogger[int]{release: make(chan struct{})}
+	cached := NewCached[int](context.Background(), inner, WithBufferSize(100))
+
+	assert.NoError(cached.Log(1))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+
+	assert.ErrorIs(cached.Flush(ctx), context.DeadlineExceeded)
+
+	close(inner.release)
+	assert.NoError(cached.Close())
+	assert.Equal([]int{1}, inner.records())
+}
+
+func TestCachedLogging_Flush_DeliveryError(t *testing.T) {
+	t.Parallel()
+	assert := require.New(t)
+
+	inner := &memoryLogger[int]{err: errors.New("sink is down")}
+	cached := NewCached[int](context.Background(), inner, WithBufferSize(100), WithRetryCount(2))
+
+	assert.NoError(cached.Log(1))
+	assert.EqualError(cached.Flush(context.Background()), "sink is down")
+	assert.Zero(inner.syncs)
+
+	// The error is reported once
+	assert.NoError(cached.Flush(context.Background()))
+	assert.NoError(cached.Close())
+}
+
+func TestCachedLogging_LogMultiple_OneWorker(t *testing.T) {
+	t.Parallel()
+	assert := require.New(t)
+
+	inner := &memoryLogger[int]{}
+	cached := NewCached[int](context.Background(), inner, WithWorkerPool(4), WithBufferSize(100))
+
+	assert.NoError(cached.LogMultiple([]int{0, 1, 2, 3, 4, 5, 6, 7}))
+	assert.NoError(cached.LogMultiple([]int{8, 9}))
+	assert.NoError(cached.Flush(context.Background()))
+
+	// Each batch is written whole, by the worker it went to
+	assert.ElementsMatch([][]int{{0, 1, 2, 3, 4, 5, 6, 7}, {8, 9}}, inner.batches)
+	assert.NoError(cached.Close())
+}
+
+func TestCachedLogging_LogMultiple_Atomic(t *testing.T) {
+	t.Parallel()
+	assert := require.New(t)
+
+	inner := &memoryLogger[int]{release: make(chan struct{})}
+	cached := NewCached[int](context.Background(), inner,
+		WithBufferSize(1),
+		WithQueueSize(4),
+		WithAtomicBatches(),
+		WithBackpressure(BackpressureDropNewest),
+	)
+
+	// The worker blocks on the first record, the queue is empty
+	assert.NoError(cached.Log(0))
+	assert.Eventually(func() bool {
+		return cached.lanes[0].workers[0].queue.len() == 0
+	}, time.Second, time.Millisecond)
+
+	assert.NoError(cached.LogMultiple([]int{1, 2, 3}))
+	// One slot left, none of the records is queued
+	assert.ErrorIs(cached.LogMultiple([]int{4, 5}), ErrRecordDropped)
+	assert.ErrorIs(cached.LogMultiple([]int{4, 5, 6, 7, 8}), ErrBatchTooLarge)
+	assert.NoError(cached.LogMultiple([]int{9}))
+
+	close(inner.release)
+	assert.NoError(cached.Close())
+
+	assert.Equal([]int{0, 1, 2, 3, 9}, inner.records())
+	assert.Equal(uint64(2), cached.Stats().Total().Dropped)
+}
+
+func TestCachedLogging_LogAfterClose(t *testing.T) {
+	t.Parallel()
+	assert := require.New(t)
+
+	inner := &memoryLogger[int]{}
+	cached := NewCached[int](context.Background(), inner, WithWorkerPool(2))
+
+	assert.NoError(cached.Log(1))
+	assert.NoError(cached.Close())
+	assert.NoError(cached.Close())
+
+	assert.ErrorIs(cached.Log(2), ErrLoggerClosed)
+	assert.ErrorIs(cached.LogMultiple([]int{3, 4}), ErrLoggerClosed)
+	assert.ErrorIs(cached.LogContext(context.Background(), 5), ErrLoggerClosed)
+	assert.ErrorIs(cached.Flush(context.Background()), ErrLoggerClosed)
+
+	assert.Equal([]int{1}, inner.records())
+}
+
+func TestCachedLogging_CloseReleasesBlockedProducers(t *testing.T) {
+	t.Parallel()
+	assert := require.New(t)
+
+	inner := &memoryLogger[int]{release: make(chan struct{})}
+	cached := NewCached[int](context.Background(), inner, WithBufferSize(1), WithQueueSize(1))
+
+	blocked := make(chan error)
+	go func() {
+		var err error
+		for i := 0; err == nil; i++ {
+			err = cached.Log(i)
+		}
+		blocked <- err
+	}()
+
+	closed := make(chan error)
+	go func() {
+		closed <- cached.Close()
+	}()
+
+	assert.ErrorIs(<-blocked, ErrLoggerClosed)
+
+	close(inner.release)
+	assert.NoError(<-closed)
+}
+
+func TestCachedLogging_ConcurrentLogAndClose(t *testing.T) {
+	t.Parallel()
+	assert := require.New(t)
+
+	inner := &memoryLogger[int]{}
+	cached := NewCached[int](context.Background(), inner, WithBufferSize(16), WithWorkerPool(4), WithQueueSize(8))
+
+	var (
+		wg       sync.WaitGroup
+		accepted atomic.Int64
+	)
+
+	for g := 0; g < 8; g++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			for i := 0; ; i++ {
+				err := cached.Log(i)
+				if errors.Is(err, ErrLoggerClosed) {
+					return
+				}
+
+				assert.NoError(err)
+				accepted.Add(1)
+			}
+		}()
+	}
+
+	time.Sleep(10 * time.Millisecond)
+	assert.NoError(cached.Close())
+	wg.Wait()
+
+	// Everything Log accepted was written
+	assert.Len(inner.records(), int(accepted.Load()))
+}
+
+func TestCachedLogging_ContextDoneCloses(t *testing.T) {
+	t.Parallel()
+	assert := require.New(t)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	inner := &memoryLogger[int]{}
+	cached := NewCached[int](ctx, inner)
+
+	assert.NoError(cached.Log(1))
+	cancel()
+
+	assert.Eventually(func() bool {
+		return errors.Is(cached.Flush(context.Background()), ErrLoggerClosed)
+	}, time.Second, time.Millisecond)
+	assert.ErrorIs(cached.Log(2), ErrLoggerClosed)
+
+	assert.NoError(cached.Close())
+	assert.Equal([]int{1}, inner.records())
+}
+
+func TestCachedLogging_Shutdown(t *testing.T) {
+	t.Parallel()
+	assert := require.New(t)
+
+	inner := &memoryLogger[int]{}
+	cached := NewCached[int](context.Background(), inner, WithWorkerPool(2))
+
+	assert.NoError(cached.LogMultiple([]int{1, 2, 3}))
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	assert.NoError(cached.Shutdown(ctx))
+	assert.ElementsMatch([]int{1, 2, 3}, inner.records())
+}
+
+func TestCachedLogging_Shutdown_HungSink(t *testing.T) {
+	t.Parallel()
+	assert := require.New(t)
+
+	errLog := error_log.NewMockLogger()
+	inner := &memoryLogger[int]{release: make(chan struct{})}
+	fallback := &memoryLogger[int]{}
+	cached := NewCached[int](context.Background(), inner,
+		WithBufferSize(2),
+		WithShutdownFallback[int](fallback),
+		WithCachedErrorLogger(errLog),
+	)
+	defer close(inner.release)
+
+	assert.NoError(cached.LogMultiple([]int{0, 1, 2, 3, 4, 5, 6, 7, 8, 9}))
+
+	// The worker hangs on the first batch of two
+	assert.Eventually(func() bool { return cached.lanes[0].workers[0].queue.len() == 8 }, time.Second, time.Millisecond)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+
+	err := cached.Shutdown(ctx)
+
+	var shutdownErr *ShutdownError
+	assert.ErrorAs(err, &shutdownErr)
+	assert.ErrorIs(err, context.DeadlineExceeded)
+	// The batch stuck in the sink can not be taken back, it is in flight
+	assert.Equal(10, shutdownErr.Undelivered)
+	assert.Equal(2, shutdownErr.InFlight)
+	assert.Equal(8, shutdownErr.Fallback)
+	assert.Equal([]int{2, 3, 4, 5, 6, 7, 8, 9}, fallback.records())
+	assert.Equal([]string{fmt.Sprintf(shutdownUndelivered, 10, 8, context.DeadlineExceeded)}, errLog.Buffer)
+
+	// Later calls report the same outcome without waiting again
+	assert.Same(err, cached.Close())
+}
+
+func TestCachedLogging_Shutdown_AbortsRetries(t *testing.T) {
+	t.Parallel()
+	assert := require.New(t)
+
+	clock := newFakeClock()
+	inner := &flakyLogger[int]{failures: 100}
+	fallback := &memoryLogger[int]{}
+	cached := NewCached[int](context.Background(), inner,
+		WithBufferSize(3),
+		WithClock(clock),
+		WithRetryCount(5),
+		WithRetryPolicy(RetryPolicy{InitialInterval: time.Hour}),
+		WithShutdownFallback[int](fallback),
+	)
+
+	assert.NoError(cached.LogMultiple([]int{1, 2, 3}))
+	assert.Eventually(func() bool { return clock.Timers() == 1 }, time.Second, time.Millisecond)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+
+	var shutdownErr *ShutdownError
+	assert.ErrorAs(cached.Shutdown(ctx), &shutdownErr)
+	assert.Equal(3, shutdownErr.Undelivered)
+	assert.Zero(shutdownErr.InFlight)
+	assert.Equal([]int{1, 2, 3}, fallback.records())
+	assert.EqualValues(1, inner.attempts.Load())
+}
+
+func TestCachedLogging_Shutdown_InFlight(t *testing.T) {
+	t.Parallel()
+	assert := require.New(t)
+
+	inner := &memoryLogger[int]{release: make(chan struct{})}
+	fallback := &memoryLogger[int]{}
+	cached := NewCached[int](context.Background(), inner,
+		WithBufferSize(10),
+		WithPauseLimit(PauseLimit{Records: 100}),
+		WithShutdownFallback[int](fallback),
+	)
+	defer close(inner.release)
+
+	assert.NoError(cached.Pause())
+
+	// One batch held by the pause, three records in the batch being built
+	for i := 0; i < 13; i++ {
+		assert.NoError(cached.Log(i))
+	}
+
+	assert.Eventually(func() bool {
+		return cached.lanes[0].workers[0].stats.buffered.Load() == 13
+	}, time.Second, time.Millisecond)
+
+	// Shutdown writes what is held first, the sink hangs on it
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+
+	var shutdownErr *ShutdownError
+	assert.ErrorAs(cached.Shutdown(ctx), &shutdownErr)
+	assert.Equal(13, shutdownErr.Undelivered)
+	assert.Equal(13, shutdownErr.InFlight)
+	assert.Zero(shutdownErr.Fallback)
+}
+
 // func TestNewWithCancel(t *testing.T) {
 // 	t.Parallel()
 // 	assert := require.New(t)
@@ -0,0 +1,46 @@
+package logger
+
+import "time"
+
+var _ Clock = systemClock{}
+
+type (
+	// Clock is the time source used by the background workers, replaceable
+	// in tests.
+	Clock interface {
+		Now() time.Time
+		NewTicker(time.Duration) Ticker
+		After(time.Duration) <-chan time.Time
+	}
+
+	Ticker interface {
+		C() <-chan time.Time
+		Stop()
+	}
+
+	systemClock struct{}
+
+	systemTicker struct {
+		ticker *time.Ticker
+	}
+)
+
+func (systemClock) Now() time.Time {
+	return time.Now()
+}
+
+func (systemClock) After(d time.Duration) <-chan time.Time {
+	return time.After(d)
+}
+
+func (systemClock) NewTicker(d time.Duration) Ticker {
+	return systemTicker{ticker: time.NewTicker(d)}
+}
+
+func (t systemTicker) C() <-chan time.Time {
+	return t.ticker.C
+}
+
+func (t systemTicker) Stop() {
+	t.ticker.Stop()
+}
@@ -0,0 +1,133 @@
+package logger
+
+import (
+	"sync"
+	"time"
+)
+
+var _ Clock = &fakeClock{}
+
+// fakeClock only moves when Advance is called, ticking every ticker whose
+// deadline has passed.
+type fakeClock struct {
+	mu      sync.Mutex
+	now     time.Time
+	tickers []*fakeTicker
+	timers  []fakeTimer
+	created chan struct{}
+}
+
+type fakeTimer struct {
+	c  chan time.Time
+	at time.Time
+}
+
+type fakeTicker struct {
+	clock   *fakeClock
+	c       chan time.Time
+	every   time.Duration
+	next    time.Time
+	stopped bool
+}
+
+func newFakeClock() *fakeClock {
+	return &fakeClock{
+		now:     time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC),
+		created: make(chan struct{}, 100),
+	}
+}
+
+func (c *fakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.now
+}
+
+func (c *fakeClock) NewTicker(d time.Duration) Ticker {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	t := &fakeTicker{
+		clock: c,
+		c:     make(chan time.Time, 1),
+		every: d,
+		next:  c.now.Add(d),
+	}
+
+	c.tickers = append(c.tickers, t)
+	c.created <- struct{}{}
+
+	return t
+}
+
+func (c *fakeClock) After(d time.Duration) <-chan time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	ch := make(chan time.Time, 1)
+
+	if d <= 0 {
+		ch <- c.now
+		return ch
+	}
+
+	c.timers = append(c.timers, fakeTimer{c: ch, at: c.now.Add(d)})
+
+	return ch
+}
+
+// Timers returns the number of After channels that have not fired yet.
+func (c *fakeClock) Timers() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return len(c.timers)
+}
+
+// WaitForTickers blocks until n tickers have been created.
+func (c *fakeClock) WaitForTickers(n int) {
+	for i := 0; i < n; i++ {
+		<-c.created
+	}
+}
+
+func (c *fakeClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.now = c.now.Add(d)
+
+	pending := c.timers[:0]
+	for _, t := range c.timers {
+		if t.at.After(c.now) {
+			pending = append(pending, t)
+			continue
+		}
+
+		t.c <- t.at
+	}
+	c.timers = pending
+
+	for _, t := range c.tickers {
+		for !t.stopped && !t.next.After(c.now) {
+			select {
+			case t.c <- t.next:
+			default:
+			}
+
+			t.next = t.next.Add(t.every)
+		}
+	}
+}
+
+func (t *fakeTicker) C() <-chan time.Time {
+	return t.c
+}
+
+func (t *fakeTicker) Stop() {
+	t.clock.mu.Lock()
+	defer t.clock.mu.Unlock()
+
+	t.stopped = true
+}
@@ -0,0 +1,299 @@
+package logger
+
+import (
+	"compress/gzip"
+	"io"
+	"os"
+	"sync"
+)
+
+// Compressor compresses a rotated file. GzipCompressor is built in; the
+// standard library has no zstd, so zstd is left to a Compressor provided by
+// the caller rather than adding a runtime dependency, for example with
+// github.com/klauspost/compress/zstd:
+//
+//	type ZstdCompressor struct{}
+//
+//	func (ZstdCompressor) Extension() string { return ".zst" }
+//
+//	func (ZstdCompressor) Compress(dst io.Writer, src io.Reader) error {
+//		w, err := zstd.NewWriter(dst)
+//		if err != nil {
+//			return err
+//		}
+//		if _, err := io.Copy(w, src); err != nil {
+//			w.Close()
+//			return err
+//		}
+//		return w.Close()
+//	}
+type Compressor interface {
+	// Extension is appended to the name of the compressed file, like ".gz"
+	Extension() string
+	Compress(dst io.Writer, src io.Reader) error
+}
+
+// GzipCompressor compresses with gzip at Level, gzip.DefaultCompression
+// when zero.
+type GzipCompressor struct {
+	Level int
+}
+
+var _ Compressor = GzipCompressor{}
+
+func (GzipCompressor) Extension() string {
+	return ".gz"
+}
+
+func (c GzipCompressor) Compress(dst io.Writer, src io.Reader) error {
+	level := c.Level
+	if level == 0 {
+		level = gzip.DefaultCompression
+	}
+
+	w, err := gzip.NewWriterLevel(dst, level)
+	if err != nil {
+		return err
+	}
+
+	if _, err := io.Copy(w, src); err != nil {
+		return err
+	}
+
+	return w.Close()
+}
+
+// WithCompression compresses the files left behind by WithSizeRotation and
+// WithTimeRotation on a background goroutine, the writes never wait for
+// it. Without either there is nothing to compress and it has no effect.
+// Numbered backups keep their number, path.1 becomes path.1.gz, and
+// MaxBackups counts the compressed ones as well. Close waits for the
+// pending files.
+func WithCompression(compressor Compressor) FileModifier {
+	return func(c *FileConfig) {
+		c.compressor = compressor
+	}
+}
+
+type compressJob struct {
+	path string
+	// handle and info are set for the backups of a FileLogger, which may
+	// be renamed or removed by the next rotations before and while they
+	// are compressed
+	handle *fileHandle
+	info   os.FileInfo
+}
+
+// CompressionQueue compresses files one after another on a goroutine of
+// its own. A file is compressed to a temporary file, synced and renamed to
+// the name with the extension of the Compressor, only then the original
+// is removed: a crash never leaves a partial archive behind.
+//
+// FileLogger uses one with WithCompression. With writers.SignalReopen,
+// queue the path of the moved file from the OnReopened callback, once the
+// old handle is closed.
+type CompressionQueue struct {
+	compressor Compressor
+	error      Error
+	mu         sync.Mutex
+	pending    []compressJob
+	closed     bool
+	wake       chan struct{}
+	done       chan struct{}
+}
+
+func NewCompressionQueue(compressor Compressor, errorLog Error) *CompressionQueue {
+	q := &CompressionQueue{
+		compressor: compressor,
+		error:      errorLog,
+		wake:       make(chan struct{}, 1),
+		done:       make(chan struct{}),
+	}
+
+	go q.run()
+
+	return q
+}
+
+// Compress queues the file at path, it never blocks. Paths queued after
+// Close are ignored.
+func (q *CompressionQueue) Compress(path string) {
+	q.push(compressJob{path: path})
+}
+
+// Close compresses the files still queued and stops the goroutine.
+func (q *CompressionQueue) Close() error {
+	q.mu.Lock()
+	q.closed = true
+	q.mu.Unlock()
+
+	q.notify()
+	<-q.done
+
+	return nil
+}
+
+func (q *CompressionQueue) push(job compressJob) {
+	q.mu.Lock()
+
+	if q.closed {
+		q.mu.Unlock()
+		return
+	}
+
+	q.pending = append(q.pending, job)
+	q.mu.Unlock()
+
+	q.notify()
+}
+
+func (q *CompressionQueue) notify() {
+	select {
+	case q.wake <- struct{}{}:
+	default:
+	}
+}
+
+func (q *CompressionQueue) run() {
+	defer close(q.done)
+
+	for {
+		q.mu.Lock()
+
+		if len(q.pending) == 0 {
+			closed := q.closed
+			q.mu.Unlock()
+
+			if closed {
+				return
+			}
+
+			<-q.wake
+			continue
+		}
+
+		job := q.pending[0]
+		q.pending[0] = compressJob{}
+		q.pending = q.pending[1:]
+		q.mu.Unlock()
+
+		if err := q.compress(job); err != nil && q.error != nil {
+			q.error.Print(failedToCompressFile, job.path, err)
+		}
+	}
+}
+
+func (q *CompressionQueue) compress(job compressJob) error {
+	open := os.Open
+	if job.handle != nil {
+		open = func(path string) (*os.File, error) {
+			return job.handle.openBackup(path, job.info)
+		}
+	}
+
+	src, err := open(job.path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			// Removed by the retention of the rotation in the meantime
+			return nil
+		}
+		return err
+	}
+
+	defer src.Close()
+
+	info, err := src.Stat()
+	if err != nil {
+		return err
+	}
+
+	ext := q.compressor.Extension()
+	tmp := job.path + ext + ".tmp"
+
+	if err := q.write(tmp, src, info.Mode().Perm()); err != nil {
+		_ = os.Remove(tmp)
+		return err
+	}
+
+	if job.handle != nil {
+		return job.handle.replaceBackup(job.path, tmp, ext, info)
+	}
+
+	if err := os.Rename(tmp, job.path+ext); err != nil {
+		_ = os.Remove(tmp)
+		return err
+	}
+
+	return os.Remove(job.path)
+}
+
+func (q *CompressionQueue) write(tmp string, src io.Reader, mode os.FileMode) error {
+	dst, err := os.OpenFile(tmp, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, mode)
+	if err != nil {
+		return err
+	}
+
+	if err := q.compressor.Compress(dst, src); err != nil {
+		_ = dst.Close()
+		return err
+	}
+
+	if err := dst.Sync(); err != nil {
+		_ = dst.Close()
+		return err
+	}
+
+	return dst.Close()
+}
+
+// openBackup opens the backup that was at path when it was queued, under
+// the name it has now.
+func (h *fileHandle) openBackup(path string, info os.FileInfo) (*os.File, error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	source := h.locate(path, info)
+	if source == "" {
+		return nil, os.ErrNotExist
+	}
+
+	// Renames after this do not affect the open file
+	return os.Open(source)
+}
+
+// replaceBackup puts the compressed file in place of the backup it was made
+// from. A rotation may have renamed the backup since, to the next number,
+// so it is looked up by its inode under the lock of the rotations.
+func (h *fileHandle) replaceBackup(path, tmp, ext string, info os.FileInfo) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	source := h.locate(path, info)
+	if source == "" {
+		// Removed by the retention of the rotation in the meantime
+		return os.Remove(tmp)
+	}
+
+	if err := os.Rename(tmp, source+ext); err != nil {
+		_ = os.Remove(tmp)
+		return err
+	}
+
+	return os.Remove(source)
+}
+
+// locate returns the current name of the file that was at path, or an
+// empty string when it is gone; the caller holds h.mu.
+func (h *fileHandle) locate(path string, info os.FileInfo) string {
+	if current, err := os.Stat(path); err == nil && os.SameFile(current, info) {
+		return path
+	}
+
+	for _, backup := range h.backups(func(string) bool { return true }) {
+		if current, err := os.Stat(backup); err == nil && os.SameFile(current, info) {
+			return backup
+		}
+	}
+
+	return ""
+}
@@ -0,0 +1,154 @@
+package logger
+
+import (
+	"compress/gzip"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/nano-interactive/go-logger/__mocks__/error_log"
+	realSerializer "github.com/nano-interactive/go-logger/serializer"
+)
+
+type failingCompressor struct{}
+
+func (failingCompressor) Extension() string {
+	return ".fail"
+}
+
+func (failingCompressor) Compress(io.Writer, io.Reader) error {
+	return errors.New("compression failed")
+}
+
+// copyCompressor stands in for a compressor provided by the caller, it
+// copies the file as it is.
+type copyCompressor struct{}
+
+func (copyCompressor) Extension() string {
+	return ".zst"
+}
+
+func (copyCompressor) Compress(dst io.Writer, src io.Reader) error {
+	_, err := io.Copy(dst, src)
+	return err
+}
+
+func readGzipLines(t *testing.T, path string) []string {
+	t.Helper()
+
+	file, err := os.Open(path)
+	require.NoError(t, err)
+	defer file.Close()
+
+	r, err := gzip.NewReader(file)
+	require.NoError(t, err)
+
+	content, err := io.ReadAll(r)
+	require.NoError(t, err)
+
+	return strings.Split(strings.TrimSuffix(string(content), "\n"), "\n")
+}
+
+func TestFileLogger_Compression(t *testing.T) {
+	t.Parallel()
+	assert := require.New(t)
+
+	dir := t.TempDir()
+	path := filepath.Join(dir, "test.json")
+
+	// Every record is 13 bytes, two fit in a file. The backups shift while
+	// they are compressed
+	fileLogger := NewFileLoggerWithOptions[logData](path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644, realSerializer.NewJson[logData](),
+		WithSizeRotation(SizeRotation{MaxSize: 30, MaxBackups: 2}),
+		WithCompression(GzipCompressor{}),
+	)
+
+	for _, name := range []string{"a", "b", "c", "d", "e", "f", "g"} {
+		assert.NoError(fileLogger.Log(logData{Name: name}))
+	}
+
+	// Waits for the compression
+	assert.NoError(fileLogger.Close())
+
+	assert.Equal([]string{`{"name":"g"}`}, readLines(t, path))
+	assert.Equal([]string{`{"name":"e"}`, `{"name":"f"}`}, readGzipLines(t, path+".1.gz"))
+	assert.Equal([]string{`{"name":"c"}`, `{"name":"d"}`}, readGzipLines(t, path+".2.gz"))
+
+	entries, err := os.ReadDir(dir)
+	assert.NoError(err)
+	assert.Len(entries, 3)
+}
+
+func TestFileLogger_Compression_CustomCompressor(t *testing.T) {
+	t.Parallel()
+	assert := require.New(t)
+
+	dir := t.TempDir()
+	path := filepath.Join(dir, "test.json")
+
+	fileLogger := NewFileLoggerWithOptions[logData](path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644, realSerializer.NewJson[logData](),
+		WithSizeRotation(SizeRotation{MaxSize: 30, MaxBackups: 2}),
+		WithCompression(copyCompressor{}),
+	)
+
+	for _, name := range []string{"a", "b", "c", "d", "e", "f", "g"} {
+		assert.NoError(fileLogger.Log(logData{Name: name}))
+	}
+
+	assert.NoError(fileLogger.Close())
+
+	// The backups are matched by the extension of the compressor
+	assert.Equal([]string{`{"name":"e"}`, `{"name":"f"}`}, readLines(t, path+".1.zst"))
+	assert.Equal([]string{`{"name":"c"}`, `{"name":"d"}`}, readLines(t, path+".2.zst"))
+
+	entries, err := os.ReadDir(dir)
+	assert.NoError(err)
+	assert.Len(entries, 3)
+}
+
+func TestCompressionQueue(t *testing.T) {
+	t.Parallel()
+	assert := require.New(t)
+
+	dir := t.TempDir()
+	path := filepath.Join(dir, "test.json")
+	assert.NoError(os.WriteFile(path, []byte("{\"name\":\"a\"}\n"), 0o600))
+
+	queue := NewCompressionQueue(GzipCompressor{}, nil)
+	queue.Compress(path)
+	queue.Compress(filepath.Join(dir, "missing.json"))
+	assert.NoError(queue.Close())
+
+	assert.NoFileExists(path)
+	assert.Equal([]string{`{"name":"a"}`}, readGzipLines(t, path+".gz"))
+
+	info, err := os.Stat(path + ".gz")
+	assert.NoError(err)
+	assert.Equal(os.FileMode(0o600), info.Mode().Perm())
+}
+
+func TestCompressionQueue_Error(t *testing.T) {
+	t.Parallel()
+	assert := require.New(t)
+
+	dir := t.TempDir()
+	path := filepath.Join(dir, "test.json")
+	assert.NoError(os.WriteFile(path, []byte("{\"name\":\"a\"}\n"), 0o600))
+
+	errLog := error_log.NewMockLogger()
+
+	queue := NewCompressionQueue(failingCompressor{}, errLog)
+	queue.Compress(path)
+	assert.NoError(queue.Close())
+
+	// The original stays, no partial archive is left behind
+	assert.FileExists(path)
+	assert.NoFileExists(path + ".fail")
+	assert.NoFileExists(path + ".fail.tmp")
+	assert.Equal([]string{`{"msg":"failed to compress the file ` + path + `","error":"compression failed"}`}, errLog.Buffer)
+}
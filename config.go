@@ -1,14 +1,49 @@
 package logger
 
+import (
+	"fmt"
+	"reflect"
+	"time"
+
+	"github.com/nano-interactive/go-logger/serializer"
+)
+
 type (
 	Config[T any] struct {
 		logger Error
+		enrich Enricher[T]
 	}
 
 	CachedLoggingConfig struct {
-		bufferSize int
-		workers    int
-		retryCount int
+		// The config is not generic, so options bound to the record type
+		// are stored as any and checked in NewCached
+		enrich           any
+		deadLetter       any
+		fallback         any
+		shardKey         any
+		batchSize        any
+		classify         any
+		spoolCodec       any
+		lanes            map[Priority]Lane
+		spoolDir         string
+		ringBuffer       bool
+		atomicBatches    bool
+		pauseLimit       PauseLimit
+		autoscale        *AutoscalePolicy
+		expvarName       string
+		spoolSize        int64
+		logger           Error
+		clock            Clock
+		bufferSize       int
+		maxBatchBytes    int
+		workers          int
+		retryCount       int
+		retryPolicy      RetryPolicy
+		retryConcurrency int
+		queueSize        int
+		backpressure     BackpressurePolicy
+		blockTimeout     time.Duration
+		flushInterval    time.Duration
 	}
 
 	Modifier[T any] func(*Config[T])
@@ -16,10 +51,23 @@ type (
 	ModifierCached func(*CachedLoggingConfig)
 )
 
+const (
+	defaultBufferSize = 1024
+	// defaultBatchCapacity preallocates batches limited by bytes only
+	defaultBatchCapacity = 1024
+)
+
 var defaultCachedConfig = CachedLoggingConfig{
-	workers:    1,
-	bufferSize: 1024,
-	retryCount: 1,
+	logger:           nopErrorLog,
+	clock:            systemClock{},
+	workers:          1,
+	retryCount:       1,
+	retryPolicy:      DefaultRetryPolicy,
+	retryConcurrency: 4,
+	spoolSize:        defaultSpoolSegmentSize,
+	queueSize:        4096,
+	backpressure:     BackpressureBlock,
+	pauseLimit:       DefaultPauseLimit,
 }
 
 func WithErrorLogger[T any](err Error) Modifier[T] {
@@ -28,6 +76,184 @@ func WithErrorLogger[T any](err Error) Modifier[T] {
 	}
 }
 
+func WithEnricher[T any](fn Enricher[T]) Modifier[T] {
+	return func(c *Config[T]) {
+		c.enrich = fn
+	}
+}
+
+// WithCachedEnricher runs fn on the caller's goroutine in LogContext and
+// LogMultipleContext, before the record is queued and the context is lost.
+func WithCachedEnricher[T any](fn Enricher[T]) ModifierCached {
+	return func(c *CachedLoggingConfig) {
+		c.enrich = fn
+	}
+}
+
+func WithCachedErrorLogger(err Error) ModifierCached {
+	return func(c *CachedLoggingConfig) {
+		c.logger = err
+	}
+}
+
+// WithQueueSize sets the capacity of each worker channel, 1 at least.
+func WithQueueSize(size int) ModifierCached {
+	return func(c *CachedLoggingConfig) {
+		c.queueSize = size
+	}
+}
+
+// WithAutoscaling adds and retires workers as the load changes, see
+// AutoscalePolicy. WithWorkerPool and Lane.Workers set the initial count.
+// A retired worker writes its queue before it exits. With WithShardKey, the
+// lane is flushed before its workers change, to keep the order per key.
+func WithAutoscaling(policy AutoscalePolicy) ModifierCached {
+	return func(c *CachedLoggingConfig) {
+		policy = policy.normalize()
+		c.autoscale = &policy
+	}
+}
+
+// WithRingBuffer replaces the worker channels with lock-free ring buffers,
+// cheaper for the producers under contention. The queue size is rounded up
+// to a power of two.
+func WithRingBuffer() ModifierCached {
+	return func(c *CachedLoggingConfig) {
+		c.ringBuffer = true
+	}
+}
+
+// WithAtomicBatches makes LogMultiple queue a batch whole or not at all.
+// The backpressure policy then applies to the batch: it waits for room for
+// every record, or drops all of them. A batch larger than the worker queue
+// fails with ErrBatchTooLarge. Claiming several slots at once needs the
+// ring buffer, so the option turns it on.
+func WithAtomicBatches() ModifierCached {
+	return func(c *CachedLoggingConfig) {
+		c.atomicBatches = true
+		c.ringBuffer = true
+	}
+}
+
+// WithPauseLimit bounds what each worker holds between Pause and Resume,
+// DefaultPauseLimit otherwise.
+func WithPauseLimit(limit PauseLimit) ModifierCached {
+	return func(c *CachedLoggingConfig) {
+		c.pauseLimit = limit
+	}
+}
+
+// WithBackpressure selects what Log does when the worker channel is full.
+func WithBackpressure(policy BackpressurePolicy) ModifierCached {
+	return func(c *CachedLoggingConfig) {
+		c.backpressure = policy
+	}
+}
+
+// WithBlockTimeout blocks on a full worker channel for at most timeout and
+// drops the record afterwards.
+func WithBlockTimeout(timeout time.Duration) ModifierCached {
+	return func(c *CachedLoggingConfig) {
+		c.backpressure = BackpressureBlockTimeout
+		c.blockTimeout = timeout
+	}
+}
+
+// WithFlushInterval makes every worker flush its partial batch at least
+// once per interval. Zero disables the time-based flush.
+func WithFlushInterval(interval time.Duration) ModifierCached {
+	return func(c *CachedLoggingConfig) {
+		c.flushInterval = interval
+	}
+}
+
+func WithClock(clock Clock) ModifierCached {
+	return func(c *CachedLoggingConfig) {
+		c.clock = clock
+	}
+}
+
+// WithMaxBatchBytes makes the workers write their batch before it grows
+// past max bytes, as estimated by size (see SerializedSize). On its own it
+// replaces the record count limit; together with WithBufferSize, whichever
+// limit is reached first ends the batch. A record is one byte at least.
+func WithMaxBatchBytes[T any](max int, size func(T) int) ModifierCached {
+	return func(c *CachedLoggingConfig) {
+		c.maxBatchBytes = max
+		c.batchSize = size
+	}
+}
+
+// WithShardKey routes every record to the worker picked by hashing its key,
+// instead of round-robin. Records with the same key keep the order in which
+// they were logged: their worker retries a failed batch before it moves on.
+func WithShardKey[T any](key func(T) uint64) ModifierCached {
+	return func(c *CachedLoggingConfig) {
+		c.shardKey = key
+	}
+}
+
+// WithPriority queues every record in the lane of the class returned by
+// classify. Each lane has its own workers and queues, so a flood of low
+// priority records does not delay the high priority ones. Unless set with
+// WithLane, every lane gets WithWorkerPool workers; the high lane blocks on
+// a full queue, the low lane drops the newest record and the normal lane
+// follows WithBackpressure. With WithShardKey, the key orders the records
+// within a lane.
+func WithPriority[T any](classify func(T) Priority) ModifierCached {
+	return func(c *CachedLoggingConfig) {
+		c.classify = classify
+	}
+}
+
+// WithLane overrides the defaults of one priority lane, see WithPriority.
+func WithLane(priority Priority, lane Lane) ModifierCached {
+	return func(c *CachedLoggingConfig) {
+		if c.lanes == nil {
+			c.lanes = make(map[Priority]Lane)
+		}
+
+		c.lanes[priority] = lane
+	}
+}
+
+// WithExpvar publishes Stats through expvar under name, next to the other
+// variables served at /debug/vars. The name stays published after Close.
+func WithExpvar(name string) ModifierCached {
+	return func(c *CachedLoggingConfig) {
+		c.expvarName = name
+	}
+}
+
+// WithShutdownFallback receives the records Shutdown could not deliver
+// before its deadline, for example a local FileLogger.
+func WithShutdownFallback[T any](log Log[T]) ModifierCached {
+	return func(c *CachedLoggingConfig) {
+		c.fallback = log
+	}
+}
+
+// WithSpool appends every record to a write-ahead spool in dir before Log
+// returns. Segments are removed once their records were delivered (or
+// dead-lettered), and segments left by a previous run are replayed into the
+// inner logger on start, so delivery is at-least-once. The codec must be
+// safe for concurrent use. With a spool, BackpressureDropOldest behaves as
+// BackpressureDropNewest, since spooled records can not be taken back.
+func WithSpool[T any](dir string, codec serializer.Codec[T]) ModifierCached {
+	return func(c *CachedLoggingConfig) {
+		c.spoolDir = dir
+		c.spoolCodec = codec
+	}
+}
+
+// WithSpoolSegmentSize sets the size at which a spool segment is sealed
+// and a new one is started.
+func WithSpoolSegmentSize(size int64) ModifierCached {
+	return func(c *CachedLoggingConfig) {
+		c.spoolSize = size
+	}
+}
+
 func WithBufferSize(size int) ModifierCached {
 	return func(c *CachedLoggingConfig) {
 		c.bufferSize = size
@@ -45,3 +271,46 @@ func WithRetryCount(count int) ModifierCached {
 		c.retryCount = count
 	}
 }
+
+func WithRetryPolicy(policy RetryPolicy) ModifierCached {
+	return func(c *CachedLoggingConfig) {
+		c.retryPolicy = policy
+	}
+}
+
+// WithRetryConcurrency limits how many failed batches are retried at the
+// same time. Once the limit is reached, workers wait for a free slot.
+func WithRetryConcurrency(n int) ModifierCached {
+	return func(c *CachedLoggingConfig) {
+		c.retryConcurrency = n
+	}
+}
+
+// WithDeadLetter receives every batch the inner logger still rejects after
+// all retries, for example a local FileLogger.
+func WithDeadLetter[T any](log Log[T]) ModifierCached {
+	return func(c *CachedLoggingConfig) {
+		c.deadLetter = log
+	}
+}
+
+// cachedOption extracts a record-typed option from CachedLoggingConfig or
+// FileConfig. A mismatch means the option was built for another record type,
+// it is reported and ignored.
+func cachedOption[T any](errorLog Error, name string, value any) T {
+	var zero T
+
+	if value == nil {
+		return zero
+	}
+
+	opt, ok := value.(T)
+	if !ok {
+		if errorLog != nil {
+			errorLog.Print(optionIgnored, name, fmt.Sprintf("%T", value), reflect.TypeOf((*T)(nil)).Elem())
+		}
+		return zero
+	}
+
+	return opt
+}
@@ -4,6 +4,26 @@ const (
 	notEnoughBytesWritten    = `{"msg":"failed to write all data to the writer","actualLen":%d,"expectedLen":%d}`
 	failedToWriteToTheFile   = `{"msg":"failed to write to the file %s","error":"%v"}`
 	failedToCloseTheFile     = `{"msg":"failed to close the file %s","error":"%v"}`
+	failedToSyncTheFile      = `{"msg":"failed to sync the file %s","error":"%v"}`
 	failedToOpenFile         = `{"msg":"failed to open the file %s","error":"%v"}`
 	failedToSerializeTheData = `{"msg":"failed to serialize the data","error":"%v"}`
+	batchDeadLettered        = `{"msg":"batch sent to the dead-letter logger after retries","records":%d,"error":"%v"}`
+	failedToDeadLetter       = `{"msg":"failed to write the batch to the dead-letter logger","records":%d,"error":"%v"}`
+	batchDiscarded           = `{"msg":"batch discarded after retries","records":%d,"error":"%v"}`
+	failedToRotateFile       = `{"msg":"failed to rotate the file %s","error":"%v"}`
+	failedToRemoveTheFile    = `{"msg":"failed to remove the file %s","error":"%v"}`
+	failedToCompressFile     = `{"msg":"failed to compress the file %s","error":"%v"}`
+	failedToApplyRetention   = `{"msg":"failed to apply the retention to %s","error":"%v"}`
+	retentionDryRun          = `{"msg":"retention would remove the file %s","reason":"%s","size":%d}`
+	failedToCreateSpool      = `{"msg":"failed to create the spool directory %s, running without spool","error":"%v"}`
+	failedToReplaySpool      = `{"msg":"failed to replay the spool segment %s","error":"%v"}`
+	spoolFrameCorrupted      = `{"msg":"corrupted frame in the spool segment %s","bytes":%d}`
+	failedToWriteFallback    = `{"msg":"failed to write undelivered records to the fallback logger","records":%d,"error":"%v"}`
+	shutdownUndelivered      = `{"msg":"shutdown interrupted before every record was delivered","undelivered":%d,"fallback":%d,"error":"%v"}`
+	retryPanicked            = `{"msg":"retry panicked, batch discarded","records":%d,"panic":"%v","stack":%q}`
+	workerPanicked           = `{"msg":"worker panicked and was restarted","worker":%d,"restarts":%d,"discarded":%d,"panic":"%v","stack":%q}`
+	expvarNameTaken          = `{"msg":"expvar name %s is already published, stats are not exported"}`
+	recordDropped            = `{"msg":"record dropped, worker queue is full","policy":"%s","priority":"%s","worker":%d,"dropped":%d}`
+	optionIgnored            = `{"msg":"%s option ignored, built for another record type","type":"%s","expected":"%s"}`
+	heldBatchDropped         = `{"msg":"held batch dropped, paused worker is over its limit","worker":%d,"records":%d,"dropped":%d}`
 )
@@ -0,0 +1,223 @@
+package logger
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// fileHandle keeps the file of a FileLogger open between writes. Before
+// every write it compares the device and inode behind the path with the
+// open file and reopens the path when they differ, after an external
+// rotation moved or removed the file. With a rotation configured, it also
+// rotates the file itself. With a path template, path is the file of the
+// current period.
+type fileHandle struct {
+	mu       sync.Mutex
+	path     string
+	flags    int
+	mode     os.FileMode
+	error    Error
+	rotation *SizeRotation
+	template *pathTemplate
+	location *time.Location
+	// compression compresses the files the rotations leave behind
+	compression *CompressionQueue
+	// retention runs after every rotation
+	retention *Retention
+	clock     Clock
+	file      *os.File
+	info      os.FileInfo
+	// size is the size of the open file, as far as this handle wrote it
+	size int64
+	// periodStart and periodEnd bound the times that map to path
+	periodStart time.Time
+	periodEnd   time.Time
+}
+
+func newFileHandle(path string, flags int, mode os.FileMode, cfg *FileConfig) *fileHandle {
+	clock := cfg.clock
+	if clock == nil {
+		clock = systemClock{}
+	}
+
+	h := &fileHandle{
+		path:     path,
+		flags:    flags,
+		mode:     mode,
+		error:    cfg.logger,
+		rotation: cfg.rotation,
+		clock:    clock,
+	}
+
+	if cfg.template != nil {
+		h.template = parsePathTemplate(path)
+		h.location = cfg.template.Location
+	}
+
+	if cfg.compressor != nil {
+		h.compression = NewCompressionQueue(cfg.compressor, cfg.logger)
+	}
+
+	if cfg.retention != nil {
+		h.retention = newRetention(*cfg.retention, cfg.logger, clock, h.active)
+	}
+
+	return h
+}
+
+// write holds the lock across the rotation check and the write, so a
+// concurrent reopen never closes the file under another writer.
+func (h *fileHandle) write(ctx context.Context, data []byte) (int, error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.switchPeriod()
+
+	file, err := h.current()
+	if err != nil {
+		return 0, err
+	}
+
+	if h.needsRotation(len(data)) {
+		h.rotate()
+
+		if file, err = h.current(); err != nil {
+			return 0, err
+		}
+	}
+
+	n, err := writeFile(ctx, h.error, h.path, file, data)
+	h.size += int64(n)
+
+	return n, err
+}
+
+// current returns the open file, reopening the path when it was rotated;
+// the caller holds h.mu.
+func (h *fileHandle) current() (*os.File, error) {
+	if h.file != nil {
+		info, err := os.Stat(h.path)
+		if err == nil && os.SameFile(info, h.info) {
+			return h.file, nil
+		}
+
+		h.closeFile()
+	}
+
+	if h.template != nil {
+		// A new period may be in a directory of its own, like %Y/%m/%d
+		if err := os.MkdirAll(filepath.Dir(h.path), 0o755); err != nil {
+			if h.error != nil {
+				h.error.Print(failedToOpenFile, h.path, err)
+			}
+			return nil, err
+		}
+	}
+
+	file, err := os.OpenFile(h.path, h.flags, h.mode)
+	if err != nil {
+		if h.error != nil {
+			h.error.Print(failedToOpenFile, h.path, err)
+		}
+		return nil, err
+	}
+
+	info, err := file.Stat()
+	if err != nil {
+		if h.error != nil {
+			h.error.Print(failedToOpenFile, h.path, err)
+		}
+		_ = file.Close()
+		return nil, err
+	}
+
+	h.file, h.info, h.size = file, info, info.Size()
+
+	return file, nil
+}
+
+// closeFile closes the open file; the caller holds h.mu.
+func (h *fileHandle) closeFile() error {
+	if h.file == nil {
+		return nil
+	}
+
+	err := h.file.Close()
+	if err != nil && h.error != nil {
+		h.error.Print(failedToCloseTheFile, h.path, err)
+	}
+
+	h.file, h.info = nil, nil
+
+	return err
+}
+
+func (h *fileHandle) sync() error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if h.file == nil {
+		return nil
+	}
+
+	if err := h.file.Sync(); err != nil {
+		if h.error != nil {
+			h.error.Print(failedToSyncTheFile, h.path, err)
+		}
+		return err
+	}
+
+	return nil
+}
+
+func (h *fileHandle) close() error {
+	h.mu.Lock()
+	err := h.closeFile()
+	h.mu.Unlock()
+
+	// Outside the lock, the queue takes it to replace the backups
+	if h.compression != nil {
+		_ = h.compression.Close()
+	}
+
+	if h.retention != nil {
+		_ = h.retention.Close()
+	}
+
+	return err
+}
+
+// active reports whether path is the file the handle writes to.
+func (h *fileHandle) active(path string) bool {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	return filepath.Clean(path) == filepath.Clean(h.path)
+}
+
+// rotated runs the retention after a rotation; the caller holds h.mu.
+func (h *fileHandle) rotated() {
+	if h.retention != nil {
+		h.retention.Trigger()
+	}
+}
+
+// compress queues a file the rotation is done with; the caller holds h.mu.
+func (h *fileHandle) compress(path string) {
+	if h.compression == nil {
+		return
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		if h.error != nil {
+			h.error.Print(failedToCompressFile, path, err)
+		}
+		return
+	}
+
+	h.compression.push(compressJob{path: path, handle: h, info: info})
+}
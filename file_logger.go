@@ -1,7 +1,11 @@
 package logger
 
 import (
+	"context"
+	"io"
 	"os"
+	"path/filepath"
+	"time"
 
 	"github.com/nano-interactive/go-logger/serializer"
 )
@@ -13,22 +17,77 @@ type (
 		path       string
 		flags      int
 		mode       os.FileMode
+		metrics    *loggerMetrics
+		// handle is set with WithPersistentHandle, the file is opened for
+		// every batch otherwise
+		handle *fileHandle
+		// retention is set by WithRetention without a handle, the handle
+		// runs it otherwise
+		retention *Retention
+		enrich    Enricher[T]
 	}
 
 	FileLoggerPooled[T any, TSerializer serializer.PooledSerializer[T]] struct {
-		pool  serializer.PoolInterface[T, TSerializer]
-		error Error
-		path  string
-		flags int
-		mode  os.FileMode
+		pool      serializer.PoolInterface[T, TSerializer]
+		error     Error
+		path      string
+		flags     int
+		mode      os.FileMode
+		metrics   *loggerMetrics
+		handle    *fileHandle
+		retention *Retention
+		enrich    Enricher[T]
 	}
+
+	FileConfig struct {
+		// enrich is stored as any, FileConfig is not generic; it is checked
+		// by the constructors
+		enrich     any
+		logger     Error
+		clock      Clock
+		rotation   *SizeRotation
+		template   *TimeRotation
+		compressor Compressor
+		retention  *RetentionPolicy
+		persistent bool
+	}
+
+	FileModifier func(*FileConfig)
 )
 
 var (
-	_ Log[any] = &FileLogger[any, *serializer.Json[any]]{}
-	_ Log[any] = &FileLoggerPooled[any, *serializer.PoolJsonSerializer[any]]{}
+	_ Log[any]        = &FileLogger[any, *serializer.Json[any]]{}
+	_ Log[any]        = &FileLoggerPooled[any, *serializer.PoolJsonSerializer[any]]{}
+	_ ContextLog[any] = &FileLogger[any, *serializer.Json[any]]{}
+	_ ContextLog[any] = &FileLoggerPooled[any, *serializer.PoolJsonSerializer[any]]{}
+	_ io.Closer       = &FileLogger[any, *serializer.Json[any]]{}
+	_ io.Closer       = &FileLoggerPooled[any, *serializer.PoolJsonSerializer[any]]{}
 )
 
+func WithFileErrorLogger(err Error) FileModifier {
+	return func(c *FileConfig) {
+		c.logger = err
+	}
+}
+
+// WithFileEnricher runs fn on every record of LogContext and
+// LogMultipleContext before it is serialized, see WithEnricher.
+func WithFileEnricher[T any](fn Enricher[T]) FileModifier {
+	return func(c *FileConfig) {
+		c.enrich = fn
+	}
+}
+
+// WithPersistentHandle keeps the file open between batches instead of
+// opening and closing it for every LogMultiple. The path is checked before
+// each write: once it points to another file, after logrotate moved or
+// removed it, the logger reopens it. Close releases the handle.
+func WithPersistentHandle() FileModifier {
+	return func(c *FileConfig) {
+		c.persistent = true
+	}
+}
+
 func NewFileLoggerWithPoolSerializer[T any, TSerializer serializer.PooledSerializer[T]](path string, flags int, mode os.FileMode, serializer serializer.PoolInterface[T, TSerializer], error ...Error) *FileLoggerPooled[T, TSerializer] {
 	var errLog Error = nil
 
@@ -36,12 +95,23 @@ func NewFileLoggerWithPoolSerializer[T any, TSerializer serializer.PooledSeriali
 		errLog = error[0]
 	}
 
+	return NewFileLoggerWithPoolSerializerAndOptions[T, TSerializer](path, flags, mode, serializer, WithFileErrorLogger(errLog))
+}
+
+func NewFileLoggerWithPoolSerializerAndOptions[T any, TSerializer serializer.PooledSerializer[T]](path string, flags int, mode os.FileMode, serializer serializer.PoolInterface[T, TSerializer], modifiers ...FileModifier) *FileLoggerPooled[T, TSerializer] {
+	cfg := newFileConfig(modifiers)
+	handle := cfg.handle(path, flags, mode)
+
 	return &FileLoggerPooled[T, TSerializer]{
-		path:  path,
-		flags: flags,
-		mode:  mode,
-		error: errLog,
-		pool:  serializer,
+		path:      path,
+		flags:     flags,
+		mode:      mode,
+		error:     cfg.logger,
+		pool:      serializer,
+		metrics:   newLoggerMetrics(),
+		handle:    handle,
+		retention: cfg.standaloneRetention(path, handle),
+		enrich:    cachedOption[Enricher[T]](cfg.logger, "file enricher", cfg.enrich),
 	}
 }
 
@@ -52,23 +122,77 @@ func NewFileLogger[T any, TSerializer serializer.Interface[T]](path string, flag
 		errLog = error[0]
 	}
 
+	return NewFileLoggerWithOptions[T](path, flags, mode, serializer, WithFileErrorLogger(errLog))
+}
+
+func NewFileLoggerWithOptions[T any, TSerializer serializer.Interface[T]](path string, flags int, mode os.FileMode, serializer TSerializer, modifiers ...FileModifier) *FileLogger[T, TSerializer] {
+	cfg := newFileConfig(modifiers)
+	handle := cfg.handle(path, flags, mode)
+
 	return &FileLogger[T, TSerializer]{
 		serializer: serializer,
 		path:       path,
 		flags:      flags,
 		mode:       mode,
-		error:      errLog,
+		error:      cfg.logger,
+		metrics:    newLoggerMetrics(),
+		handle:     handle,
+		retention:  cfg.standaloneRetention(path, handle),
+		enrich:     cachedOption[Enricher[T]](cfg.logger, "file enricher", cfg.enrich),
+	}
+}
+
+func newFileConfig(modifiers []FileModifier) FileConfig {
+	var cfg FileConfig
+
+	for _, modifier := range modifiers {
+		modifier(&cfg)
+	}
+
+	return cfg
+}
+
+func (c *FileConfig) handle(path string, flags int, mode os.FileMode) *fileHandle {
+	if !c.persistent && c.rotation == nil && c.template == nil {
+		return nil
+	}
+
+	return newFileHandle(path, flags, mode, c)
+}
+
+// standaloneRetention starts the retention of a logger without a handle,
+// it only runs periodically then as nothing is rotated.
+func (c *FileConfig) standaloneRetention(path string, handle *fileHandle) *Retention {
+	if c.retention == nil || handle != nil {
+		return nil
 	}
+
+	active := filepath.Clean(path)
+
+	return newRetention(*c.retention, c.logger, c.clock, func(file string) bool {
+		return filepath.Clean(file) == active
+	})
 }
 
-//go:inline
-func serializeToFile[T any, TSerializer serializer.Interface[T]](errorLog Error, path string, flags int, mode os.FileMode, serializer TSerializer, data []T) error {
+func serializeToFileContext[T any, TSerializer serializer.Interface[T]](ctx context.Context, errorLog Error, handle *fileHandle, path string, flags int, mode os.FileMode, serializer TSerializer, data []T) (int, error) {
+	if err := ctx.Err(); err != nil {
+		return 0, err
+	}
+
 	rawData, err := serializer.Serialize(data)
 	if err != nil {
 		if errorLog != nil {
 			errorLog.Print(failedToSerializeTheData, err)
 		}
-		return err
+		return 0, err
+	}
+
+	if err = ctx.Err(); err != nil {
+		return 0, err
+	}
+
+	if handle != nil {
+		return handle.write(ctx, rawData)
 	}
 
 	file, err := os.OpenFile(path, flags, mode)
@@ -77,7 +201,7 @@ func serializeToFile[T any, TSerializer serializer.Interface[T]](errorLog Error,
 		if errorLog != nil {
 			errorLog.Print(failedToOpenFile, path, err)
 		}
-		return err
+		return 0, err
 	}
 
 	defer func(file *os.File) {
@@ -87,23 +211,37 @@ func serializeToFile[T any, TSerializer serializer.Interface[T]](errorLog Error,
 		}
 	}(file)
 
+	return writeFile(ctx, errorLog, path, file, rawData)
+}
+
+// writeFile writes the serialized batch to the open file.
+func writeFile(ctx context.Context, errorLog Error, path string, file *os.File, rawData []byte) (int, error) {
+	clearDeadline := withWriteDeadline(ctx, file)
+	defer clearDeadline()
+
 	n, err := file.Write(rawData)
 	if err != nil {
 		if errorLog != nil {
 			errorLog.Print(failedToWriteToTheFile, path, err)
 		}
-		return err
+
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return n, ctxErr
+		}
+
+		return n, err
 	}
 
 	if n != len(rawData) && errorLog != nil {
 		errorLog.Print(notEnoughBytesWritten, n, len(rawData))
 	}
 
-	return nil
+	return n, nil
 }
 
 func (l *FileLogger[T, TSerializer]) LogMultiple(data []T) error {
-	return serializeToFile(l.error, l.path, l.flags, l.mode, l.serializer, data)
+	_, err := l.write(context.Background(), data)
+	return err
 }
 
 func (l *FileLogger[T, TSerializer]) Log(data T) error {
@@ -112,13 +250,130 @@ func (l *FileLogger[T, TSerializer]) Log(data T) error {
 	return l.LogMultiple(many[:])
 }
 
+func (l *FileLogger[T, TSerializer]) LogMultipleContext(ctx context.Context, data []T) error {
+	_, err := l.write(ctx, enrich(ctx, l.enrich, data))
+	return err
+}
+
+func (l *FileLogger[T, TSerializer]) logMultipleSized(data []T) (int, error) {
+	return l.write(context.Background(), data)
+}
+
+func (l *FileLogger[T, TSerializer]) write(ctx context.Context, data []T) (int, error) {
+	start := time.Now()
+	n, err := serializeToFileContext(ctx, l.error, l.handle, l.path, l.flags, l.mode, l.serializer, data)
+	l.metrics.observe(start, len(data), n, err)
+
+	return n, err
+}
+
+// Metrics returns the records and bytes written since NewFileLogger.
+func (l *FileLogger[T, TSerializer]) Metrics() Metrics {
+	return l.metrics.snapshot()
+}
+
+func (l *FileLogger[T, TSerializer]) LogContext(ctx context.Context, data T) error {
+	many := [...]T{data}
+
+	return l.LogMultipleContext(ctx, many[:])
+}
+
 func (l *FileLoggerPooled[T, TSerializer]) Log(data T) error {
 	many := [...]T{data}
 	return l.LogMultiple(many[:])
 }
 
 func (l *FileLoggerPooled[T, TSerializer]) LogMultiple(data []T) error {
+	_, err := l.write(context.Background(), data)
+	return err
+}
+
+func (l *FileLoggerPooled[T, TSerializer]) LogContext(ctx context.Context, data T) error {
+	many := [...]T{data}
+	return l.LogMultipleContext(ctx, many[:])
+}
+
+func (l *FileLoggerPooled[T, TSerializer]) LogMultipleContext(ctx context.Context, data []T) error {
+	_, err := l.write(ctx, enrich(ctx, l.enrich, data))
+	return err
+}
+
+func (l *FileLoggerPooled[T, TSerializer]) logMultipleSized(data []T) (int, error) {
+	return l.write(context.Background(), data)
+}
+
+func (l *FileLoggerPooled[T, TSerializer]) write(ctx context.Context, data []T) (int, error) {
 	s := l.pool.Acquire()
 	defer l.pool.Release(s)
-	return serializeToFile(l.error, l.path, l.flags, l.mode, s, data)
+
+	start := time.Now()
+	n, err := serializeToFileContext(ctx, l.error, l.handle, l.path, l.flags, l.mode, s, data)
+	l.metrics.observe(start, len(data), n, err)
+
+	return n, err
+}
+
+func (l *FileLoggerPooled[T, TSerializer]) Metrics() Metrics {
+	return l.metrics.snapshot()
+}
+
+// Sync commits the data written so far to stable storage.
+func (l *FileLogger[T, TSerializer]) Sync() error {
+	if l.handle != nil {
+		return l.handle.sync()
+	}
+
+	return syncFile(l.error, l.path, l.flags, l.mode)
+}
+
+func (l *FileLoggerPooled[T, TSerializer]) Sync() error {
+	if l.handle != nil {
+		return l.handle.sync()
+	}
+
+	return syncFile(l.error, l.path, l.flags, l.mode)
+}
+
+// Close releases the file kept open by WithPersistentHandle, a later write
+// opens it again, and stops the background work of WithCompression and
+// WithRetention.
+func (l *FileLogger[T, TSerializer]) Close() error {
+	return closeFileLogger(l.handle, l.retention)
+}
+
+func (l *FileLoggerPooled[T, TSerializer]) Close() error {
+	return closeFileLogger(l.handle, l.retention)
+}
+
+func closeFileLogger(handle *fileHandle, retention *Retention) error {
+	if retention != nil {
+		_ = retention.Close()
+	}
+
+	if handle != nil {
+		return handle.close()
+	}
+
+	return nil
+}
+
+func syncFile(errorLog Error, path string, flags int, mode os.FileMode) error {
+	// Only the write intent matters, truncating here would lose the data
+	file, err := os.OpenFile(path, flags&^os.O_TRUNC, mode)
+	if err != nil {
+		if errorLog != nil {
+			errorLog.Print(failedToOpenFile, path, err)
+		}
+		return err
+	}
+
+	if err = file.Sync(); err != nil {
+		if errorLog != nil {
+			errorLog.Print(failedToSyncTheFile, path, err)
+		}
+		_ = file.Close()
+		return err
+	}
+
+	return file.Close()
 }
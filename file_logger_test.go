@@ -8,6 +8,7 @@ import (
 	"os"
 	"path/filepath"
 	"strings"
+	"sync"
 	"testing"
 
 	"github.com/stretchr/testify/require"
@@ -110,6 +111,30 @@ func BenchmarkFileLogger_OneByOne(b *testing.B) {
 	}
 }
 
+func BenchmarkFileLogger_PersistentHandle(b *testing.B) {
+	type data struct {
+		name    string
+		surname string
+	}
+
+	dir := b.TempDir()
+	s := realSerializer.NewJson[data]()
+	logger := NewFileLoggerWithOptions[data](filepath.Join(dir, "test.json"), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644, s, WithPersistentHandle())
+	defer logger.Close()
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		err := logger.Log(data{
+			name:    "test",
+			surname: "test",
+		})
+
+		if err != nil {
+			b.Errorf("Failed to log the data: %v", err)
+		}
+	}
+}
+
 func BenchmarkFileLogger_Cached(b *testing.B) {
 	type data struct {
 		name    string
@@ -259,3 +284,138 @@ func BenchmarkFileLogger_Cached_Batches(b *testing.B) {
 		b.Errorf("Expected %d lines, got %d", numOfLines, len(lines))
 	}
 }
+
+func TestFileLogger_LogContext_Canceled(t *testing.T) {
+	t.Parallel()
+	assert := require.New(t)
+
+	dir := t.TempDir()
+	path := filepath.Join(dir, "test.json")
+
+	fileLogger := NewFileLogger[logData](path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644, realSerializer.NewJson[logData]())
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	assert.ErrorIs(fileLogger.LogContext(ctx, logData{Name: "test"}), context.Canceled)
+	assert.NoFileExists(path)
+
+	assert.NoError(fileLogger.LogContext(context.Background(), logData{Name: "test"}))
+	assert.FileExists(path)
+}
+
+func TestFileLogger_LogContext_Enricher(t *testing.T) {
+	t.Parallel()
+	assert := require.New(t)
+
+	dir := t.TempDir()
+	path := filepath.Join(dir, "test.json")
+
+	fileLogger := NewFileLoggerWithOptions[logData](path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644, realSerializer.NewJson[logData](),
+		WithFileEnricher(func(ctx context.Context, data logData) logData {
+			data.Name += "-" + ctx.Value(ctxKey{}).(string)
+			return data
+		}),
+	)
+
+	ctx := context.WithValue(context.Background(), ctxKey{}, "req-1")
+	data := []logData{{Name: "test 1"}}
+
+	assert.NoError(fileLogger.LogMultipleContext(ctx, data))
+	assert.Equal([]string{`{"name":"test 1-req-1"}`}, readLines(t, path))
+	// The caller's slice must stay untouched
+	assert.Equal("test 1", data[0].Name)
+}
+
+func TestFileLogger_Sync(t *testing.T) {
+	t.Parallel()
+	assert := require.New(t)
+
+	path := filepath.Join(t.TempDir(), "test.json")
+	fileLogger := NewFileLogger[logData](path, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0o644, realSerializer.NewJson[logData]())
+
+	assert.NoError(fileLogger.Log(logData{Name: "test"}))
+	assert.NoError(fileLogger.Sync())
+
+	// Sync must not truncate what was already written
+	content, err := os.ReadFile(path)
+	assert.NoError(err)
+	assert.Equal("{\"name\":\"test\"}\n", string(content))
+}
+
+func TestFileLogger_PersistentHandle_Reopen(t *testing.T) {
+	t.Parallel()
+	assert := require.New(t)
+
+	dir := t.TempDir()
+	path := filepath.Join(dir, "test.json")
+
+	fileLogger := NewFileLoggerWithOptions[logData](path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644, realSerializer.NewJson[logData](),
+		WithPersistentHandle(),
+	)
+
+	assert.NoError(fileLogger.Log(logData{Name: "a"}))
+	file := fileLogger.handle.file
+	assert.NoError(fileLogger.Log(logData{Name: "b"}))
+	assert.Same(file, fileLogger.handle.file)
+
+	// Rotated by an external tool, the next write goes to a new file
+	assert.NoError(os.Rename(path, path+".1"))
+	assert.NoError(fileLogger.Log(logData{Name: "c"}))
+	assert.NotSame(file, fileLogger.handle.file)
+
+	// Removed, it is created again
+	assert.NoError(os.Remove(path + ".1"))
+	assert.NoError(os.Remove(path))
+	assert.NoError(fileLogger.Log(logData{Name: "d"}))
+
+	assert.NoError(fileLogger.Sync())
+	assert.NoError(fileLogger.Close())
+
+	content, err := os.ReadFile(path)
+	assert.NoError(err)
+	assert.Equal("{\"name\":\"d\"}\n", string(content))
+}
+
+func TestFileLogger_PersistentHandle_Concurrent(t *testing.T) {
+	t.Parallel()
+	assert := require.New(t)
+
+	dir := t.TempDir()
+	path := filepath.Join(dir, "test.json")
+
+	fileLogger := NewFileLoggerWithOptions[logData](path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644, realSerializer.NewJson[logData](),
+		WithPersistentHandle(),
+	)
+
+	const writers, records = 8, 100
+
+	assert.NoError(fileLogger.Log(logData{Name: "test"}))
+
+	var wg sync.WaitGroup
+	wg.Add(writers)
+
+	for i := 0; i < writers; i++ {
+		go func() {
+			defer wg.Done()
+
+			for j := 0; j < records; j++ {
+				assert.NoError(fileLogger.Log(logData{Name: "test"}))
+			}
+		}()
+	}
+
+	// Rotate while the writers run
+	assert.NoError(os.Rename(path, path+".1"))
+	wg.Wait()
+	assert.NoError(fileLogger.Close())
+
+	lines := 0
+	for _, name := range []string{path, path + ".1"} {
+		content, err := os.ReadFile(name)
+		assert.NoError(err)
+		lines += strings.Count(string(content), "\n")
+	}
+
+	assert.Equal(writers*records+1, lines)
+}
@@ -1,7 +1,9 @@
 package logger
 
 import (
+	"context"
 	"io"
+	"time"
 
 	"github.com/nano-interactive/go-logger/serializer"
 )
@@ -21,22 +23,55 @@ type (
 		LogMultiple([]T) error
 	}
 
+	// ContextLog is implemented by loggers that respect the deadline and
+	// cancellation of the passed context. When the context is done before the
+	// records are handed over, ctx.Err() is returned.
+	ContextLog[T any] interface {
+		Log[T]
+		LogContext(context.Context, T) error
+		LogMultipleContext(context.Context, []T) error
+	}
+
+	// Enricher copies request-scoped values from the context into the record
+	// before it enters the logging pipeline.
+	Enricher[T any] func(context.Context, T) T
+
+	// sizedLog is implemented by the loggers of this package, so the cached
+	// workers can count the bytes written
+	sizedLog[T any] interface {
+		logMultipleSized([]T) (int, error)
+	}
+
+	syncer interface {
+		Sync() error
+	}
+
+	deadlineWriter interface {
+		SetWriteDeadline(time.Time) error
+	}
+
 	GenericLogger[T any, TSerializer serializer.Interface[T]] struct {
 		error      Error
 		serializer TSerializer
 		handle     io.Writer
+		enrich     Enricher[T]
+		metrics    *loggerMetrics
 	}
 
 	GenericLoggerPooled[T any, TSerializer serializer.PooledSerializer[T]] struct {
-		error  Error
-		handle io.Writer
-		pool   serializer.PoolInterface[T, TSerializer]
+		error   Error
+		handle  io.Writer
+		pool    serializer.PoolInterface[T, TSerializer]
+		enrich  Enricher[T]
+		metrics *loggerMetrics
 	}
 )
 
 var (
-	_ Log[any] = &GenericLoggerPooled[any, *serializer.PoolJsonSerializer[any]]{}
-	_ Log[any] = &GenericLogger[any, *serializer.Json[any]]{}
+	_ Log[any]        = &GenericLoggerPooled[any, *serializer.PoolJsonSerializer[any]]{}
+	_ Log[any]        = &GenericLogger[any, *serializer.Json[any]]{}
+	_ ContextLog[any] = &GenericLoggerPooled[any, *serializer.PoolJsonSerializer[any]]{}
+	_ ContextLog[any] = &GenericLogger[any, *serializer.Json[any]]{}
 )
 
 func NewWithPooledSerializer[T any, TSerializer serializer.PooledSerializer[T]](w io.Writer, serializer serializer.PoolInterface[T, TSerializer], modifiers ...Modifier[T]) *GenericLoggerPooled[T, TSerializer] {
@@ -49,8 +84,11 @@ func NewWithPooledSerializer[T any, TSerializer serializer.PooledSerializer[T]](
 	}
 
 	l := &GenericLoggerPooled[T, TSerializer]{
-		pool:   serializer,
-		handle: w,
+		error:   cfg.logger,
+		pool:    serializer,
+		handle:  w,
+		enrich:  cfg.enrich,
+		metrics: newLoggerMetrics(),
 	}
 
 	return l
@@ -66,30 +104,82 @@ func New[T any, TSerializer serializer.Interface[T]](w io.Writer, serializer TSe
 	}
 
 	l := &GenericLogger[T, TSerializer]{
+		error:      cfg.logger,
 		serializer: serializer,
 		handle:     w,
+		enrich:     cfg.enrich,
+		metrics:    newLoggerMetrics(),
 	}
 
 	return l
 }
 
 //go:inline
+func enrich[T any](ctx context.Context, fn Enricher[T], data []T) []T {
+	if fn == nil {
+		return data
+	}
+
+	// The caller owns data, so the enriched records go into a new slice
+	enriched := make([]T, len(data))
+	for i, v := range data {
+		enriched[i] = fn(ctx, v)
+	}
+
+	return enriched
+}
+
+// withWriteDeadline applies the context deadline to writers that support it
+// (sockets, pipes) and returns the function that clears it again.
+func withWriteDeadline(ctx context.Context, handle any) func() {
+	deadline, ok := ctx.Deadline()
+	if !ok {
+		return func() {}
+	}
+
+	w, ok := handle.(deadlineWriter)
+	if !ok || w.SetWriteDeadline(deadline) != nil {
+		return func() {}
+	}
+
+	return func() {
+		_ = w.SetWriteDeadline(time.Time{})
+	}
+}
+
+// serializeContext writes the batch and returns the number of bytes written.
+func serializeContext[T any, TSerialize serializer.Interface[T]](ctx context.Context, handle io.Writer, errorLog Error, serializer TSerialize, data []T) (int, error) {
+	if err := ctx.Err(); err != nil {
+		return 0, err
+	}
 
-func serialize[T any, TSerialize serializer.Interface[T]](handle io.Writer, errorLog Error, serializer TSerialize, data []T) error {
 	rawData, err := serializer.Serialize(data)
 	if err != nil {
 		if errorLog != nil {
 			errorLog.Print(failedToSerializeTheData, err)
 		}
-		return err
+		return 0, err
+	}
+
+	// Serialization of a large batch can outlive the deadline
+	if err = ctx.Err(); err != nil {
+		return 0, err
 	}
 
+	clearDeadline := withWriteDeadline(ctx, handle)
+	defer clearDeadline()
+
 	n, err := handle.Write(rawData)
 	if err != nil {
 		if errorLog != nil {
 			errorLog.Print(failedToWriteToTheFile, "", err)
 		}
-		return err
+
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return n, ctxErr
+		}
+
+		return n, err
 	}
 
 	if n != len(rawData) {
@@ -98,7 +188,7 @@ func serialize[T any, TSerialize serializer.Interface[T]](handle io.Writer, erro
 		}
 	}
 
-	return nil
+	return n, nil
 }
 
 func (l *GenericLogger[T, TSerializer]) Log(data T) error {
@@ -108,7 +198,46 @@ func (l *GenericLogger[T, TSerializer]) Log(data T) error {
 }
 
 func (l *GenericLogger[T, TSerializer]) LogMultiple(data []T) error {
-	return serialize(l.handle, l.error, l.serializer, data)
+	_, err := l.write(context.Background(), data)
+	return err
+}
+
+func (l *GenericLogger[T, TSerializer]) LogContext(ctx context.Context, data T) error {
+	many := [...]T{data}
+
+	return l.LogMultipleContext(ctx, many[:])
+}
+
+func (l *GenericLogger[T, TSerializer]) LogMultipleContext(ctx context.Context, data []T) error {
+	_, err := l.write(ctx, enrich(ctx, l.enrich, data))
+	return err
+}
+
+func (l *GenericLogger[T, TSerializer]) logMultipleSized(data []T) (int, error) {
+	return l.write(context.Background(), data)
+}
+
+func (l *GenericLogger[T, TSerializer]) write(ctx context.Context, data []T) (int, error) {
+	start := time.Now()
+	n, err := serializeContext(ctx, l.handle, l.error, l.serializer, data)
+	l.metrics.observe(start, len(data), n, err)
+
+	return n, err
+}
+
+// Metrics returns the records and bytes written since New.
+func (l *GenericLogger[T, TSerializer]) Metrics() Metrics {
+	return l.metrics.snapshot()
+}
+
+// Sync commits the written data to stable storage when the underlying
+// writer supports it (*os.File, writers.SignalReopen).
+func (l *GenericLogger[T, TSerializer]) Sync() error {
+	if s, ok := l.handle.(syncer); ok {
+		return s.Sync()
+	}
+
+	return nil
 }
 
 func (l *GenericLogger[T, TSerializer]) Close() error {
@@ -126,9 +255,46 @@ func (l *GenericLoggerPooled[T, TSerializer]) Log(data T) error {
 }
 
 func (l *GenericLoggerPooled[T, TSerializer]) LogMultiple(data []T) error {
+	_, err := l.write(context.Background(), data)
+	return err
+}
+
+func (l *GenericLoggerPooled[T, TSerializer]) LogContext(ctx context.Context, data T) error {
+	many := [...]T{data}
+
+	return l.LogMultipleContext(ctx, many[:])
+}
+
+func (l *GenericLoggerPooled[T, TSerializer]) LogMultipleContext(ctx context.Context, data []T) error {
+	_, err := l.write(ctx, enrich(ctx, l.enrich, data))
+	return err
+}
+
+func (l *GenericLoggerPooled[T, TSerializer]) logMultipleSized(data []T) (int, error) {
+	return l.write(context.Background(), data)
+}
+
+func (l *GenericLoggerPooled[T, TSerializer]) write(ctx context.Context, data []T) (int, error) {
 	s := l.pool.Acquire()
 	defer l.pool.Release(s)
-	return serialize(l.handle, l.error, s, data)
+
+	start := time.Now()
+	n, err := serializeContext(ctx, l.handle, l.error, s, data)
+	l.metrics.observe(start, len(data), n, err)
+
+	return n, err
+}
+
+func (l *GenericLoggerPooled[T, TSerializer]) Metrics() Metrics {
+	return l.metrics.snapshot()
+}
+
+func (l *GenericLoggerPooled[T, TSerializer]) Sync() error {
+	if s, ok := l.handle.(syncer); ok {
+		return s.Sync()
+	}
+
+	return nil
 }
 
 func (l *GenericLoggerPooled[T, TSerializer]) Close() error {
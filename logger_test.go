@@ -445,3 +445,45 @@ func BenchmarkGenericLogger_Cached_Batches(b *testing.B) {
 		b.Errorf("Expected %d lines, got %d", numOfLines, len(lines))
 	}
 }
+
+func TestLogContext_Enricher(t *testing.T) {
+	t.Parallel()
+	assert := require.New(t)
+
+	buff := bytes.NewBuffer(make([]byte, 0, 100))
+
+	logger := New[logData](buff, realSerializer.NewJson[logData](), WithEnricher(func(ctx context.Context, data logData) logData {
+		data.Name += "-" + ctx.Value(ctxKey{}).(string)
+		return data
+	}))
+
+	ctx := context.WithValue(context.Background(), ctxKey{}, "req-1")
+	data := []logData{{Name: "test 1"}}
+
+	assert.NoError(logger.LogMultipleContext(ctx, data))
+	assert.Equal("{\"name\":\"test 1-req-1\"}\n", buff.String())
+	// The caller's slice must stay untouched
+	assert.Equal("test 1", data[0].Name)
+}
+
+func TestLogContext_Canceled(t *testing.T) {
+	t.Parallel()
+	assert := require.New(t)
+
+	buff := &writer.MockWriteCloser{}
+	ser := &serializer.MockSerializer[logData]{}
+
+	logger := &GenericLogger[logData, *serializer.MockSerializer[logData]]{
+		serializer: ser,
+		handle:     buff,
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	err := logger.LogContext(ctx, logData{Name: "test 1"})
+
+	assert.ErrorIs(err, context.Canceled)
+	ser.AssertNotCalled(t, "Serialize")
+	buff.AssertNotCalled(t, "Write")
+}
@@ -0,0 +1,237 @@
+package logger
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/nano-interactive/go-logger/serializer"
+)
+
+// LatencyBuckets are the upper bounds, in seconds, of the flush latency
+// histogram.
+var LatencyBuckets = []float64{0.0005, 0.001, 0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10}
+
+type (
+	// Histogram is a snapshot of a latency histogram. Counts are cumulative,
+	// Counts[i] holds the observations up to Buckets[i] seconds.
+	Histogram struct {
+		Buckets []float64
+		Counts  []uint64
+		Count   uint64
+		// Sum is in seconds
+		Sum float64
+	}
+
+	// Metrics is a snapshot of the counters of a logger since it was created.
+	Metrics struct {
+		Records uint64
+		Bytes   uint64
+		Errors  uint64
+		Dropped uint64
+		// QueueDepth is the number of records waiting for a worker, only
+		// CachedLogging queues records
+		QueueDepth   int
+		FlushLatency Histogram
+	}
+
+	// MetricsSource is implemented by every logger of this package.
+	MetricsSource interface {
+		Metrics() Metrics
+	}
+)
+
+// latencyHistogram counts the observations per bucket, the last one
+// being +Inf.
+type latencyHistogram struct {
+	counts []atomic.Uint64
+	sum    atomic.Int64
+}
+
+func newLatencyHistogram() *latencyHistogram {
+	return &latencyHistogram{counts: make([]atomic.Uint64, len(LatencyBuckets)+1)}
+}
+
+func (h *latencyHistogram) observe(d time.Duration) {
+	seconds := d.Seconds()
+	i := sort.SearchFloat64s(LatencyBuckets, seconds)
+
+	h.counts[i].Add(1)
+	h.sum.Add(int64(d))
+}
+
+func (h *latencyHistogram) snapshot() Histogram {
+	snapshot := Histogram{
+		Buckets: LatencyBuckets,
+		Counts:  make([]uint64, len(LatencyBuckets)),
+		Sum:     time.Duration(h.sum.Load()).Seconds(),
+	}
+
+	for i := range h.counts {
+		snapshot.Count += h.counts[i].Load()
+
+		if i < len(snapshot.Counts) {
+			snapshot.Counts[i] = snapshot.Count
+		}
+	}
+
+	return snapshot
+}
+
+// loggerMetrics counts the writes of a GenericLogger or a FileLogger. A nil
+// pointer, as in a logger built without its constructor, counts nothing.
+type loggerMetrics struct {
+	records atomic.Uint64
+	bytes   atomic.Uint64
+	errors  atomic.Uint64
+	latency *latencyHistogram
+}
+
+func newLoggerMetrics() *loggerMetrics {
+	return &loggerMetrics{latency: newLatencyHistogram()}
+}
+
+func (m *loggerMetrics) observe(start time.Time, records, bytes int, err error) {
+	if m == nil {
+		return
+	}
+
+	m.latency.observe(time.Since(start))
+	m.bytes.Add(uint64(bytes))
+
+	if err != nil {
+		m.errors.Add(1)
+		return
+	}
+
+	m.records.Add(uint64(records))
+}
+
+func (m *loggerMetrics) snapshot() Metrics {
+	if m == nil {
+		return Metrics{FlushLatency: newLatencyHistogram().snapshot()}
+	}
+
+	return Metrics{
+		Records:      m.records.Load(),
+		Bytes:        m.bytes.Load(),
+		Errors:       m.errors.Load(),
+		FlushLatency: m.latency.snapshot(),
+	}
+}
+
+// MetricsHandler serves the metrics of the registered loggers in the
+// Prometheus text exposition format, every series labelled by the name the
+// logger was registered under.
+type MetricsHandler struct {
+	mu      sync.RWMutex
+	sources map[string]MetricsSource
+}
+
+var (
+	_ http.Handler  = &MetricsHandler{}
+	_ MetricsSource = &CachedLogging[any]{}
+	_ MetricsSource = &GenericLogger[any, *serializer.Json[any]]{}
+	_ MetricsSource = &GenericLoggerPooled[any, *serializer.PoolJsonSerializer[any]]{}
+	_ MetricsSource = &FileLogger[any, *serializer.Json[any]]{}
+	_ MetricsSource = &FileLoggerPooled[any, *serializer.PoolJsonSerializer[any]]{}
+)
+
+func NewMetricsHandler() *MetricsHandler {
+	return &MetricsHandler{sources: make(map[string]MetricsSource)}
+}
+
+// Register adds the logger under name, replacing the one registered before.
+func (h *MetricsHandler) Register(name string, source MetricsSource) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.sources[name] = source
+}
+
+func (h *MetricsHandler) Unregister(name string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	delete(h.sources, name)
+}
+
+func (h *MetricsHandler) ServeHTTP(w http.ResponseWriter, _ *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	_, _ = h.WriteTo(w)
+}
+
+// WriteTo writes the metrics of every logger, sorted by name.
+func (h *MetricsHandler) WriteTo(w io.Writer) (int64, error) {
+	h.mu.RLock()
+	names := make([]string, 0, len(h.sources))
+	for name := range h.sources {
+		names = append(names, name)
+	}
+
+	metrics := make(map[string]Metrics, len(names))
+	for _, name := range names {
+		metrics[name] = h.sources[name].Metrics()
+	}
+	h.mu.RUnlock()
+
+	sort.Strings(names)
+
+	out := &strings.Builder{}
+
+	counters := []struct {
+		name, help string
+		value      func(Metrics) uint64
+	}{
+		{"go_logger_records_total", "Records written by the logger.", func(m Metrics) uint64 { return m.Records }},
+		{"go_logger_bytes_total", "Bytes written by the logger.", func(m Metrics) uint64 { return m.Bytes }},
+		{"go_logger_errors_total", "Failed writes of the logger.", func(m Metrics) uint64 { return m.Errors }},
+		{"go_logger_dropped_total", "Records dropped by the backpressure policy.", func(m Metrics) uint64 { return m.Dropped }},
+	}
+
+	for _, c := range counters {
+		fmt.Fprintf(out, "# HELP %s %s\n# TYPE %s counter\n", c.name, c.help, c.name)
+		for _, name := range names {
+			fmt.Fprintf(out, "%s{logger=\"%s\"} %d\n", c.name, escapeLabel(name), c.value(metrics[name]))
+		}
+	}
+
+	out.WriteString("# HELP go_logger_queue_depth Records waiting for a worker.\n# TYPE go_logger_queue_depth gauge\n")
+	for _, name := range names {
+		fmt.Fprintf(out, "go_logger_queue_depth{logger=\"%s\"} %d\n", escapeLabel(name), metrics[name].QueueDepth)
+	}
+
+	out.WriteString("# HELP go_logger_flush_duration_seconds Time spent writing a batch.\n# TYPE go_logger_flush_duration_seconds histogram\n")
+	for _, name := range names {
+		label := escapeLabel(name)
+		hist := metrics[name].FlushLatency
+
+		for i, le := range hist.Buckets {
+			fmt.Fprintf(out, "go_logger_flush_duration_seconds_bucket{logger=\"%s\",le=\"%s\"} %d\n", label, formatFloat(le), hist.Counts[i])
+		}
+
+		fmt.Fprintf(out, "go_logger_flush_duration_seconds_bucket{logger=\"%s\",le=\"+Inf\"} %d\n", label, hist.Count)
+		fmt.Fprintf(out, "go_logger_flush_duration_seconds_sum{logger=\"%s\"} %s\n", label, formatFloat(hist.Sum))
+		fmt.Fprintf(out, "go_logger_flush_duration_seconds_count{logger=\"%s\"} %d\n", label, hist.Count)
+	}
+
+	n, err := io.WriteString(w, out.String())
+
+	return int64(n), err
+}
+
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+func escapeLabel(value string) string {
+	return labelEscaper.Replace(value)
+}
+
+func formatFloat(value float64) string {
+	return strconv.FormatFloat(value, 'g', -1, 64)
+}
@@ -0,0 +1,84 @@
+package logger
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/nano-interactive/go-logger/serializer"
+)
+
+func TestMetricsHandler(t *testing.T) {
+	t.Parallel()
+	assert := require.New(t)
+
+	buf := &bytes.Buffer{}
+	inner := New[string](buf, serializer.NewJson[string]())
+	cached := NewCached[string](context.Background(), inner, WithBufferSize(2))
+
+	file := NewFileLogger[string](filepath.Join(t.TempDir(), "log.json"), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644, serializer.NewJson[string]())
+
+	assert.NoError(cached.LogMultiple([]string{"a", "b", "c"}))
+	assert.NoError(cached.Flush(context.Background()))
+	assert.NoError(file.Log("a"))
+
+	handler := NewMetricsHandler()
+	handler.Register("cached", cached)
+	handler.Register("generic", inner)
+	handler.Register(`file "1"`, file)
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+
+	assert.Equal("text/plain; version=0.0.4; charset=utf-8", rec.Header().Get("Content-Type"))
+
+	body, err := io.ReadAll(rec.Body)
+	assert.NoError(err)
+
+	lines := strings.Split(string(body), "\n")
+
+	assert.Contains(lines, "# TYPE go_logger_records_total counter")
+	assert.Contains(lines, `go_logger_records_total{logger="cached"} 3`)
+	assert.Contains(lines, `go_logger_records_total{logger="generic"} 3`)
+	assert.Contains(lines, `go_logger_records_total{logger="file \"1\""} 1`)
+	assert.Contains(lines, `go_logger_bytes_total{logger="cached"} 12`)
+	assert.Contains(lines, `go_logger_bytes_total{logger="file \"1\""} 4`)
+	assert.Contains(lines, `go_logger_errors_total{logger="generic"} 0`)
+	assert.Contains(lines, `go_logger_queue_depth{logger="cached"} 0`)
+	assert.Contains(lines, "# TYPE go_logger_flush_duration_seconds histogram")
+	assert.Contains(lines, `go_logger_flush_duration_seconds_bucket{logger="cached",le="+Inf"} 2`)
+	assert.Contains(lines, `go_logger_flush_duration_seconds_count{logger="generic"} 2`)
+
+	handler.Unregister("generic")
+
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+	assert.NotContains(rec.Body.String(), `logger="generic"`)
+
+	assert.NoError(cached.Close())
+}
+
+func TestLatencyHistogram(t *testing.T) {
+	t.Parallel()
+	assert := require.New(t)
+
+	h := newLatencyHistogram()
+	h.observe(0)
+	h.observe(500 * time.Microsecond)
+	h.observe(3 * time.Second)
+	h.observe(1000 * time.Second)
+
+	snapshot := h.snapshot()
+	assert.Equal(uint64(4), snapshot.Count)
+	assert.Equal(uint64(2), snapshot.Counts[0])
+	assert.Equal(uint64(3), snapshot.Counts[len(snapshot.Counts)-1])
+	assert.InDelta(1003.0005, snapshot.Sum, 1e-9)
+}
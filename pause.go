@@ -0,0 +1,195 @@
+package logger
+
+import "errors"
+
+type PauseOverflow uint8
+
+const (
+	// PauseOverflowBackpressure stops taking records off the queue, which
+	// fills up and the backpressure policy of the lane applies.
+	PauseOverflowBackpressure PauseOverflow = iota
+	// PauseOverflowDropOldest discards the oldest held batch to make room.
+	PauseOverflowDropOldest
+	// PauseOverflowWrite writes the oldest held batch to make room, the
+	// pause only holds what fits in the limit.
+	PauseOverflowWrite
+)
+
+// PauseLimit bounds what every worker holds while paused. Bytes is only
+// counted with WithMaxBatchBytes, which sizes the records. SpoolBytes bounds
+// the segments of the worker's spool with WithSpool; they are removed whole,
+// so the overflow may take more than one batch to free one. Zero means no
+// limit, a limit only applies to whole batches: a worker holds at least one.
+type PauseLimit struct {
+	Records    int
+	Bytes      int
+	SpoolBytes int64
+	Overflow   PauseOverflow
+}
+
+var DefaultPauseLimit = PauseLimit{
+	Records:  64 * 1024,
+	Overflow: PauseOverflowBackpressure,
+}
+
+// ErrPaused is returned by Flush while the logger is paused.
+var ErrPaused = errors.New("logger: cached logger is paused")
+
+func (o PauseOverflow) String() string {
+	switch o {
+	case PauseOverflowBackpressure:
+		return "backpressure"
+	case PauseOverflowDropOldest:
+		return "drop_oldest"
+	case PauseOverflowWrite:
+		return "write"
+	default:
+		return "unknown"
+	}
+}
+
+// heldBatch is a batch a paused worker did not write. start is the spool
+// sequence number of its first record.
+type heldBatch[T any] struct {
+	records []T
+	bytes   int
+	start   uint64
+}
+
+// Pause stops the workers from writing to the inner logger, for example
+// while the sink is under maintenance. They keep receiving records and
+// hold the full batches, up to the limit set by WithPauseLimit. With a
+// spool, the held records survive a crash as well.
+//
+// Flush returns ErrPaused until Resume. Close and Shutdown write everything
+// regardless of the pause, autoscaling waits for Resume.
+func (l *CachedLogging[T]) Pause() error {
+	return l.setPaused(true)
+}
+
+// Resume writes the batches held since Pause, in order, and lets the
+// workers write again.
+func (l *CachedLogging[T]) Resume() error {
+	return l.setPaused(false)
+}
+
+// Paused reports whether Pause was called without Resume.
+func (l *CachedLogging[T]) Paused() bool {
+	return l.paused.Load()
+}
+
+func (l *CachedLogging[T]) setPaused(paused bool) error {
+	// Reconfigure must not start or retire workers half way
+	l.reconfigMu.Lock()
+	defer l.reconfigMu.Unlock()
+
+	// Not mu for writing: the producers blocked by a paused worker hold it
+	// for reading until Resume reaches that worker
+	l.mu.RLock()
+	closed := l.closed
+	l.mu.RUnlock()
+
+	if closed {
+		return ErrLoggerClosed
+	}
+
+	// Workers started from now on pick the state up in startWorker, those
+	// started before are in the list below
+	l.paused.Store(paused)
+
+	for _, w := range l.workers() {
+		select {
+		case w.pauses <- paused:
+		case <-w.done:
+		case <-l.stopping:
+			return ErrLoggerClosed
+		}
+	}
+
+	return nil
+}
+
+// setPaused runs on the worker goroutine.
+func (w *logWorker[T]) setPaused(paused bool) {
+	w.paused = paused
+
+	if !paused {
+		w.writeHeld()
+	}
+}
+
+// hold keeps the batch instead of writing it, the cache starts over.
+func (w *logWorker[T]) hold() {
+	w.held = append(w.held, heldBatch[T]{records: w.cache, bytes: w.bytes, start: w.start})
+	w.heldRecords += len(w.cache)
+	w.heldBytes += w.bytes
+	w.stats.held.Add(int64(len(w.cache)))
+
+	w.start += uint64(len(w.cache))
+	w.cache = make([]T, 0, cap(w.cache))
+	w.bytes = 0
+
+	if w.pauseLimit.Overflow == PauseOverflowBackpressure {
+		return
+	}
+
+	// The batch just held stays, whatever its size
+	for len(w.held) > 1 && w.heldOver() {
+		batch := w.takeHeld()
+
+		if w.pauseLimit.Overflow == PauseOverflowWrite {
+			w.writeRecords(batch.records, batch.start)
+			continue
+		}
+
+		// Dropped for good, the spool must not replay them either
+		if w.spool != nil {
+			w.spool.ack(batch.start, batch.start+uint64(len(batch.records)))
+		}
+
+		w.stats.dropped.Add(uint64(len(batch.records)))
+		w.stats.buffered.Add(-int64(len(batch.records)))
+		dropped := w.dropped.Add(uint64(len(batch.records)))
+		w.error.Print(heldBatchDropped, w.id, len(batch.records), dropped)
+	}
+}
+
+// heldFull reports whether the held batches reached the pause limit.
+func (w *logWorker[T]) heldFull() bool {
+	limit := w.pauseLimit
+
+	return (limit.Records > 0 && w.heldRecords >= limit.Records) ||
+		(limit.Bytes > 0 && w.heldBytes >= limit.Bytes) ||
+		(limit.SpoolBytes > 0 && w.spool != nil && len(w.held) > 0 && w.spool.disk.Load() >= limit.SpoolBytes)
+}
+
+// heldOver reports whether the held batches went past the pause limit.
+func (w *logWorker[T]) heldOver() bool {
+	limit := w.pauseLimit
+
+	return (limit.Records > 0 && w.heldRecords > limit.Records) ||
+		(limit.Bytes > 0 && w.heldBytes > limit.Bytes) ||
+		(limit.SpoolBytes > 0 && w.spool != nil && w.spool.disk.Load() > limit.SpoolBytes)
+}
+
+func (w *logWorker[T]) takeHeld() heldBatch[T] {
+	batch := w.held[0]
+	w.held[0] = heldBatch[T]{}
+	w.held = w.held[1:]
+
+	w.heldRecords -= len(batch.records)
+	w.heldBytes -= batch.bytes
+	w.stats.held.Add(-int64(len(batch.records)))
+
+	return batch
+}
+
+// writeHeld writes the held batches, oldest first.
+func (w *logWorker[T]) writeHeld() {
+	for len(w.held) > 0 {
+		batch := w.takeHeld()
+		w.writeRecords(batch.records, batch.start)
+	}
+
+	w.held = nil
+}
@@ -0,0 +1,164 @@
+package logger
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	realSerializer "github.com/nano-interactive/go-logger/serializer"
+)
+
+func TestCachedLogging_Pause(t *testing.T) {
+	t.Parallel()
+	assert := require.New(t)
+
+	inner := &memoryLogger[int]{}
+	cached := NewCached[int](context.Background(), inner, WithBufferSize(2))
+
+	assert.NoError(cached.Pause())
+	assert.True(cached.Stats().Paused)
+
+	for i := 0; i < 5; i++ {
+		assert.NoError(cached.Log(i))
+	}
+
+	// The two full batches are held, the last record waits in the cache
+	assert.Eventually(func() bool {
+		return cached.Stats().Total().Held == 4
+	}, time.Second, time.Millisecond)
+	assert.Empty(inner.records())
+	assert.ErrorIs(cached.Flush(context.Background()), ErrPaused)
+
+	assert.NoError(cached.Resume())
+	assert.NoError(cached.Flush(context.Background()))
+	assert.Zero(cached.Stats().Total().Held)
+	assert.False(cached.Stats().Paused)
+
+	assert.NoError(cached.Close())
+	assert.Equal([][]int{{0, 1}, {2, 3}, {4}}, inner.batches)
+}
+
+func TestCachedLogging_Pause_DropOldest(t *testing.T) {
+	t.Parallel()
+	assert := require.New(t)
+
+	inner := &memoryLogger[int]{}
+	cached := NewCached[int](context.Background(), inner,
+		WithBufferSize(2),
+		WithPauseLimit(PauseLimit{Records: 4, Overflow: PauseOverflowDropOldest}),
+	)
+
+	assert.NoError(cached.Pause())
+
+	for i := 0; i < 8; i++ {
+		assert.NoError(cached.Log(i))
+	}
+
+	assert.Eventually(func() bool {
+		return cached.Dropped() == 4
+	}, time.Second, time.Millisecond)
+	assert.Equal(4, cached.Stats().Total().Held)
+
+	// Close writes what is held, paused or not
+	assert.NoError(cached.Close())
+	assert.Equal([]int{4, 5, 6, 7}, inner.records())
+}
+
+func TestCachedLogging_Pause_Backpressure(t *testing.T) {
+	t.Parallel()
+	assert := require.New(t)
+
+	inner := &memoryLogger[int]{}
+	cached := NewCached[int](context.Background(), inner,
+		WithBufferSize(2),
+		WithQueueSize(2),
+		WithBackpressure(BackpressureDropNewest),
+		WithPauseLimit(PauseLimit{Records: 2}),
+	)
+
+	assert.NoError(cached.Pause())
+
+	assert.NoError(cached.Log(0))
+	assert.NoError(cached.Log(1))
+	assert.Eventually(func() bool {
+		return cached.Stats().Total().Held == 2
+	}, time.Second, time.Millisecond)
+
+	// The worker holds one batch and stops receiving, the queue fills up
+	assert.NoError(cached.Log(2))
+	assert.NoError(cached.Log(3))
+	assert.ErrorIs(cached.Log(4), ErrRecordDropped)
+	assert.Empty(inner.records())
+
+	assert.NoError(cached.Resume())
+	assert.NoError(cached.Close())
+	assert.Equal([]int{0, 1, 2, 3}, inner.records())
+}
+
+func TestCachedLogging_Pause_Block(t *testing.T) {
+	t.Parallel()
+	assert := require.New(t)
+
+	inner := &memoryLogger[int]{}
+	cached := NewCached[int](context.Background(), inner,
+		WithBufferSize(2),
+		WithQueueSize(4),
+		WithPauseLimit(PauseLimit{Records: 4}),
+	)
+
+	assert.NoError(cached.Pause())
+
+	logged := make(chan struct{})
+
+	go func() {
+		defer close(logged)
+
+		for i := 0; i < 20; i++ {
+			assert.NoError(cached.Log(i))
+		}
+	}()
+
+	// The worker holds its limit and the producer blocks on the full queue
+	assert.Eventually(func() bool {
+		return cached.Stats().Total().Held == 4 && cached.lanes[0].workers[0].queue.len() == 4
+	}, time.Second, time.Millisecond)
+
+	assert.NoError(cached.Resume())
+	<-logged
+
+	assert.NoError(cached.Close())
+	assert.Len(inner.records(), 20)
+}
+
+func TestCachedLogging_Pause_SpoolBytes(t *testing.T) {
+	t.Parallel()
+	assert := require.New(t)
+
+	inner := &memoryLogger[logData]{}
+	cached := NewCached[logData](context.Background(), inner,
+		WithBufferSize(2),
+		WithQueueSize(2),
+		WithBackpressure(BackpressureDropNewest),
+		WithSpool[logData](t.TempDir(), realSerializer.NewJson[logData]()),
+		WithPauseLimit(PauseLimit{SpoolBytes: 1}),
+	)
+
+	assert.NoError(cached.Pause())
+
+	assert.NoError(cached.Log(logData{Name: "a"}))
+	assert.NoError(cached.Log(logData{Name: "b"}))
+	assert.Eventually(func() bool {
+		return cached.Stats().Total().Held == 2
+	}, time.Second, time.Millisecond)
+
+	// The spool is over its limit, the worker stops receiving
+	assert.NoError(cached.Log(logData{Name: "c"}))
+	assert.NoError(cached.Log(logData{Name: "d"}))
+	assert.ErrorIs(cached.Log(logData{Name: "e"}), ErrRecordDropped)
+
+	assert.NoError(cached.Resume())
+	assert.NoError(cached.Close())
+	assert.Equal([]logData{{Name: "a"}, {Name: "b"}, {Name: "c"}, {Name: "d"}}, inner.records())
+}
@@ -0,0 +1,155 @@
+package logger
+
+import (
+	"fmt"
+	"sync/atomic"
+	"time"
+)
+
+// Priority is the class a record is queued in, see WithPriority.
+type Priority uint8
+
+const (
+	PriorityHigh Priority = iota
+	PriorityNormal
+	PriorityLow
+
+	priorityCount = int(PriorityLow) + 1
+)
+
+func (p Priority) String() string {
+	switch p {
+	case PriorityHigh:
+		return "high"
+	case PriorityNormal:
+		return "normal"
+	case PriorityLow:
+		return "low"
+	default:
+		return "unknown"
+	}
+}
+
+func (p Priority) MarshalText() ([]byte, error) {
+	return []byte(p.String()), nil
+}
+
+func (p *Priority) UnmarshalText(text []byte) error {
+	for candidate := PriorityHigh; int(candidate) < priorityCount; candidate++ {
+		if candidate.String() == string(text) {
+			*p = candidate
+			return nil
+		}
+	}
+
+	return fmt.Errorf("logger: unknown priority %q", text)
+}
+
+// Lane sets the queue and the worker budget of one priority class.
+type Lane struct {
+	// Workers defaults to WithWorkerPool
+	Workers int
+	// QueueSize is the capacity of each worker channel of the lane and
+	// defaults to WithQueueSize
+	QueueSize    int
+	Backpressure BackpressurePolicy
+	BlockTimeout time.Duration
+}
+
+// lane is the set of workers that serves one priority class.
+type lane[T any] struct {
+	priority     Priority
+	queueSize    int
+	backpressure BackpressurePolicy
+	blockTimeout time.Duration
+	// workers is replaced, never changed in place, under CachedLogging.mu
+	workers []*logWorker[T]
+	// idx spreads the records of the lane round-robin
+	idx uint64
+	// initial is the number of workers started by NewCached
+	initial int
+	// pinned lanes got their worker count from WithLane
+	pinned bool
+	// blocked is set while a rebalance moves the shard keys of the lane,
+	// producers counts the producers routing records in it, see enter
+	blocked   atomic.Pointer[chan struct{}]
+	producers atomic.Int64
+}
+
+// newLanes lays out the lanes, without their workers. Without a classifier,
+// every worker serves a single lane.
+func newLanes[T any](config *CachedLoggingConfig, classified bool) []*lane[T] {
+	if !classified {
+		return []*lane[T]{{
+			priority:     PriorityNormal,
+			queueSize:    config.queueSize,
+			backpressure: config.backpressure,
+			blockTimeout: config.blockTimeout,
+			initial:      config.workers,
+		}}
+	}
+
+	lanes := make([]*lane[T], priorityCount)
+
+	for p := range lanes {
+		cfg, ok := config.lanes[Priority(p)]
+		if !ok {
+			cfg = Lane{Backpressure: config.backpressure, BlockTimeout: config.blockTimeout}
+
+			// High never gives up a record, low is the first to go
+			switch Priority(p) {
+			case PriorityHigh:
+				cfg.Backpressure = BackpressureBlock
+			case PriorityLow:
+				cfg.Backpressure = BackpressureDropNewest
+			}
+		}
+
+		// Reconfigure leaves the worker count set by WithLane alone
+		pinned := cfg.Workers > 0
+		if !pinned {
+			cfg.Workers = config.workers
+		}
+
+		if cfg.QueueSize <= 0 {
+			cfg.QueueSize = config.queueSize
+		}
+
+		lanes[p] = &lane[T]{
+			priority:     Priority(p),
+			queueSize:    cfg.QueueSize,
+			backpressure: cfg.Backpressure,
+			blockTimeout: cfg.BlockTimeout,
+			initial:      cfg.Workers,
+			pinned:       pinned,
+		}
+	}
+
+	return lanes
+}
+
+// laneOf classifies the record. Unknown priorities go to the low lane.
+func (l *CachedLogging[T]) laneOf(log T) *lane[T] {
+	if l.classify == nil {
+		return l.lanes[0]
+	}
+
+	p := l.classify(log)
+	if int(p) >= len(l.lanes) {
+		p = PriorityLow
+	}
+
+	return l.lanes[p]
+}
+
+// worker picks the worker of the lane for the record, by shard key or by
+// seq when there is none. The caller holds l.mu.
+func (l *CachedLogging[T]) worker(ln *lane[T], log T, seq uint64) *logWorker[T] {
+	n := uint64(len(ln.workers))
+
+	if l.shardKey != nil {
+		return ln.workers[shard(l.shardKey(log), n)]
+	}
+
+	return ln.workers[seq%n]
+}
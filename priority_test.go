@@ -0,0 +1,114 @@
+package logger
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/nano-interactive/go-logger/__mocks__/error_log"
+)
+
+func classifyPrefix(record string) Priority {
+	switch {
+	case strings.HasPrefix(record, "audit"):
+		return PriorityHigh
+	case strings.HasPrefix(record, "debug"):
+		return PriorityLow
+	default:
+		return PriorityNormal
+	}
+}
+
+// laneLogger holds the batches with debug records until release is closed.
+type laneLogger struct {
+	memoryLogger[string]
+	release chan struct{}
+}
+
+func (l *laneLogger) LogMultiple(data []string) error {
+	for _, record := range data {
+		if strings.HasPrefix(record, "debug") {
+			<-l.release
+			break
+		}
+	}
+
+	return l.memoryLogger.LogMultiple(data)
+}
+
+func TestCachedLogging_Priority_HighNotBehindLow(t *testing.T) {
+	t.Parallel()
+	assert := require.New(t)
+
+	inner := &laneLogger{release: make(chan struct{})}
+	cached := NewCached[string](context.Background(), inner,
+		WithBufferSize(1),
+		WithPriority(classifyPrefix),
+		WithLane(PriorityLow, Lane{Workers: 1, QueueSize: 2, Backpressure: BackpressureDropNewest}),
+	)
+
+	// The low lane is stuck on the first record and its queue fills up
+	for i := 0; i < 10; i++ {
+		_ = cached.Log("debug")
+	}
+
+	assert.NoError(cached.Log("audit"))
+	assert.NoError(cached.Log("info"))
+
+	assert.Eventually(func() bool {
+		return len(inner.records()) == 2
+	}, time.Second, time.Millisecond)
+	assert.ElementsMatch([]string{"audit", "info"}, inner.records())
+
+	close(inner.release)
+	assert.NoError(cached.Close())
+}
+
+func TestCachedLogging_Priority_LowDropsFirst(t *testing.T) {
+	t.Parallel()
+	assert := require.New(t)
+
+	errLog := error_log.NewMockLogger()
+	inner := &memoryLogger[string]{release: make(chan struct{})}
+	cached := NewCached[string](context.Background(), inner,
+		WithBufferSize(1),
+		WithQueueSize(1),
+		WithCachedErrorLogger(errLog),
+		WithPriority(classifyPrefix),
+	)
+
+	// Both lanes have one worker stuck in the inner logger and a full queue
+	assert.NoError(cached.Log("debug 1"))
+	assert.NoError(cached.Log("audit 1"))
+
+	assert.Eventually(func() bool {
+		return cached.lanes[PriorityLow].workers[0].queue.len() == 0 &&
+			cached.lanes[PriorityHigh].workers[0].queue.len() == 0
+	}, time.Second, time.Millisecond)
+
+	assert.NoError(cached.Log("debug 2"))
+	assert.NoError(cached.Log("audit 2"))
+
+	assert.ErrorIs(cached.Log("debug 3"), ErrRecordDropped)
+
+	blocked := make(chan error, 1)
+	go func() {
+		blocked <- cached.Log("audit 3")
+	}()
+
+	select {
+	case <-blocked:
+		t.Fatal("high priority record must wait instead of being dropped")
+	case <-time.After(10 * time.Millisecond):
+	}
+
+	close(inner.release)
+
+	assert.NoError(<-blocked)
+	assert.NoError(cached.Close())
+	assert.Equal(uint64(1), cached.Dropped())
+	assert.ElementsMatch([]string{"debug 1", "debug 2", "audit 1", "audit 2", "audit 3"}, inner.records())
+}
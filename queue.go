@@ -0,0 +1,376 @@
+package logger
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// queue holds the records between the producers and one worker.
+type queue[T any] interface {
+	// tryPush queues the record if there is room, without blocking
+	tryPush(T) bool
+	// tryPushBatch queues as many records as there is room for, in order,
+	// without blocking and returns how many it took
+	tryPushBatch([]T) int
+	// push waits for room until ctx is done, stop is closed (ErrLoggerClosed)
+	// or timeout fires (ErrRecordDropped). A nil timeout never fires.
+	push(ctx context.Context, data T, stop <-chan struct{}, timeout <-chan time.Time) error
+	// popBatch moves up to len(buf) records into buf without blocking
+	popBatch(buf []T) int
+	len() int
+	cap() int
+
+	// The worker waits on recv for a channel and on ready for a ring,
+	// the other one is nil
+	recv() <-chan T
+	ready() <-chan struct{}
+	// park is called before the worker waits, it returns false when
+	// records are already available
+	park() bool
+}
+
+// batchQueue queues a whole batch or nothing. Only the ring buffer can
+// claim several slots at once.
+type batchQueue[T any] interface {
+	tryPushAll([]T) bool
+	pushAll(ctx context.Context, data []T, stop <-chan struct{}, timeout <-chan time.Time) error
+}
+
+func newQueue[T any](size int, ring bool) queue[T] {
+	if ring {
+		return newRingQueue[T](size)
+	}
+
+	return chanQueue[T](make(chan T, size))
+}
+
+// alwaysReady wakes a worker that has records without waiting
+var alwaysReady = func() chan struct{} {
+	ch := make(chan struct{})
+	close(ch)
+	return ch
+}()
+
+type chanQueue[T any] chan T
+
+func (q chanQueue[T]) tryPush(data T) bool {
+	select {
+	case q <- data:
+		return true
+	default:
+		return false
+	}
+}
+
+func (q chanQueue[T]) tryPushBatch(data []T) int {
+	for i := range data {
+		if !q.tryPush(data[i]) {
+			return i
+		}
+	}
+
+	return len(data)
+}
+
+func (q chanQueue[T]) push(ctx context.Context, data T, stop <-chan struct{}, timeout <-chan time.Time) error {
+	select {
+	case q <- data:
+		return nil
+	case <-timeout:
+		return ErrRecordDropped
+	case <-stop:
+		return ErrLoggerClosed
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (q chanQueue[T]) popBatch(buf []T) int {
+	for i := range buf {
+		select {
+		case buf[i] = <-q:
+		default:
+			return i
+		}
+	}
+
+	return len(buf)
+}
+
+func (q chanQueue[T]) len() int {
+	return len(q)
+}
+
+func (q chanQueue[T]) cap() int {
+	return cap(q)
+}
+
+func (q chanQueue[T]) recv() <-chan T {
+	return q
+}
+
+func (q chanQueue[T]) ready() <-chan struct{} {
+	return nil
+}
+
+func (q chanQueue[T]) park() bool {
+	return true
+}
+
+type ringSlot[T any] struct {
+	// seq tells the state of the slot: equal to the position when free,
+	// one past it when it holds a record
+	seq  atomic.Uint64
+	data T
+}
+
+// ringQueue is a bounded multi-producer queue (Vyukov's design). The
+// producers claim slots with a CAS on head and never lock; the consumer
+// side is serialized by popMu, which only the worker takes outside of
+// shutdown and BackpressureDropOldest.
+type ringQueue[T any] struct {
+	head atomic.Uint64
+	_    [56]byte
+	tail atomic.Uint64
+	_    [56]byte
+
+	popMu sync.Mutex
+	mask  uint64
+	slots []ringSlot[T]
+
+	// sleeping is set by the worker before it waits on wake, so the
+	// producers only pay for a channel send when somebody listens
+	sleeping atomic.Bool
+	wake     chan struct{}
+	// space is signalled when the worker frees slots, for blocked producers
+	space chan struct{}
+}
+
+// newRingQueue rounds the size up to a power of two, at least 2.
+func newRingQueue[T any](size int) *ringQueue[T] {
+	capacity := 2
+	for capacity < size {
+		capacity <<= 1
+	}
+
+	q := &ringQueue[T]{
+		mask:  uint64(capacity - 1),
+		slots: make([]ringSlot[T], capacity),
+		wake:  make(chan struct{}, 1),
+		space: make(chan struct{}, 1),
+	}
+
+	for i := range q.slots {
+		q.slots[i].seq.Store(uint64(i))
+	}
+
+	return q
+}
+
+func (q *ringQueue[T]) tryPush(data T) bool {
+	for {
+		pos := q.head.Load()
+		slot := &q.slots[pos&q.mask]
+		seq := slot.seq.Load()
+
+		switch diff := int64(seq - pos); {
+		case diff == 0:
+			if !q.head.CompareAndSwap(pos, pos+1) {
+				continue
+			}
+
+			slot.data = data
+			slot.seq.Store(pos + 1)
+			q.notify()
+
+			return true
+		case diff < 0:
+			// The slot still holds the record from the previous lap
+			return false
+		}
+		// Another producer took the slot, try the next one
+	}
+}
+
+func (q *ringQueue[T]) tryPushBatch(data []T) int {
+	pos, n := q.reserve(len(data), false)
+	q.fill(pos, data[:n])
+
+	return n
+}
+
+func (q *ringQueue[T]) tryPushAll(data []T) bool {
+	pos, n := q.reserve(len(data), true)
+	if n < len(data) {
+		return false
+	}
+
+	q.fill(pos, data)
+
+	return true
+}
+
+func (q *ringQueue[T]) pushAll(ctx context.Context, data []T, stop <-chan struct{}, timeout <-chan time.Time) error {
+	for !q.tryPushAll(data) {
+		select {
+		case <-q.space:
+		case <-timeout:
+			return ErrRecordDropped
+		case <-stop:
+			return ErrLoggerClosed
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+
+	if q.len() <= int(q.mask) {
+		signal(q.space)
+	}
+
+	return nil
+}
+
+// reserve claims up to n consecutive slots with a single CAS and returns
+// the first position and how many it got. With all set, it claims n slots
+// or none.
+func (q *ringQueue[T]) reserve(n int, all bool) (uint64, int) {
+	for {
+		// head first, so the free count can not go negative
+		pos := q.head.Load()
+		// The worker frees the slots in order, everything before tail is free
+		free := int(q.tail.Load() + uint64(len(q.slots)) - pos)
+
+		claim := n
+		if free < claim {
+			if all {
+				return pos, 0
+			}
+
+			claim = free
+		}
+
+		if claim == 0 {
+			return pos, 0
+		}
+
+		if q.head.CompareAndSwap(pos, pos+uint64(claim)) {
+			return pos, claim
+		}
+	}
+}
+
+// fill publishes the records into the slots claimed by reserve
+func (q *ringQueue[T]) fill(pos uint64, data []T) {
+	if len(data) == 0 {
+		return
+	}
+
+	for i := range data {
+		slot := &q.slots[(pos+uint64(i))&q.mask]
+		slot.data = data[i]
+		slot.seq.Store(pos + uint64(i) + 1)
+	}
+
+	q.notify()
+}
+
+// notify wakes the worker when it sleeps
+func (q *ringQueue[T]) notify() {
+	if q.sleeping.Load() && q.sleeping.CompareAndSwap(true, false) {
+		signal(q.wake)
+	}
+}
+
+func (q *ringQueue[T]) push(ctx context.Context, data T, stop <-chan struct{}, timeout <-chan time.Time) error {
+	for !q.tryPush(data) {
+		select {
+		case <-q.space:
+		case <-timeout:
+			return ErrRecordDropped
+		case <-stop:
+			return ErrLoggerClosed
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+
+	// Pass the wake-up on to the next blocked producer
+	if q.len() <= int(q.mask) {
+		signal(q.space)
+	}
+
+	return nil
+}
+
+func (q *ringQueue[T]) popBatch(buf []T) int {
+	q.popMu.Lock()
+	defer q.popMu.Unlock()
+
+	var zero T
+	n := 0
+
+	for ; n < len(buf); n++ {
+		pos := q.tail.Load()
+		slot := &q.slots[pos&q.mask]
+
+		if slot.seq.Load() != pos+1 {
+			break
+		}
+
+		buf[n] = slot.data
+		slot.data = zero
+		slot.seq.Store(pos + q.mask + 1)
+		q.tail.Store(pos + 1)
+	}
+
+	if n > 0 {
+		signal(q.space)
+	}
+
+	return n
+}
+
+func (q *ringQueue[T]) len() int {
+	// tail first, head can only have moved further in the meantime
+	tail := q.tail.Load()
+	head := q.head.Load()
+
+	if n := int(head - tail); n <= len(q.slots) {
+		return n
+	}
+
+	return len(q.slots)
+}
+
+func (q *ringQueue[T]) cap() int {
+	return len(q.slots)
+}
+
+func (q *ringQueue[T]) recv() <-chan T {
+	return nil
+}
+
+func (q *ringQueue[T]) ready() <-chan struct{} {
+	return q.wake
+}
+
+func (q *ringQueue[T]) park() bool {
+	q.sleeping.Store(true)
+
+	// A record pushed before the flag was visible would not wake us
+	if q.len() > 0 {
+		q.sleeping.Store(false)
+		return false
+	}
+
+	return true
+}
+
+func signal(ch chan struct{}) {
+	select {
+	case ch <- struct{}{}:
+	default:
+	}
+}
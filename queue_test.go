@@ -0,0 +1,252 @@
+package logger
+
+import (
+	"context"
+	"runtime"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestRingQueue(t *testing.T) {
+	t.Parallel()
+	assert := require.New(t)
+
+	q := newRingQueue[int](3)
+	assert.Len(q.slots, 4)
+
+	for i := 0; i < 4; i++ {
+		assert.True(q.tryPush(i))
+	}
+
+	assert.False(q.tryPush(4))
+	assert.Equal(4, q.len())
+
+	buf := make([]int, 3)
+	assert.Equal(3, q.popBatch(buf))
+	assert.Equal([]int{0, 1, 2}, buf)
+
+	// The freed slots are reused on the next lap
+	for i := 4; i < 7; i++ {
+		assert.True(q.tryPush(i))
+	}
+
+	assert.Equal(3, q.popBatch(buf))
+	assert.Equal([]int{3, 4, 5}, buf)
+	assert.Equal(1, q.popBatch(buf))
+	assert.Equal(6, buf[0])
+	assert.Equal(0, q.popBatch(buf))
+	assert.Equal(0, q.len())
+}
+
+func TestRingQueue_Batch(t *testing.T) {
+	t.Parallel()
+	assert := require.New(t)
+
+	q := newRingQueue[int](4)
+
+	assert.Equal(4, q.tryPushBatch([]int{1, 2, 3, 4, 5, 6}))
+	assert.False(q.tryPushAll([]int{7}))
+
+	buf := make([]int, 2)
+	assert.Equal(2, q.popBatch(buf))
+	assert.Equal([]int{1, 2}, buf)
+
+	// Two slots are free, the batch of three is not split
+	assert.False(q.tryPushAll([]int{7, 8, 9}))
+	assert.Equal(2, q.len())
+	assert.True(q.tryPushAll([]int{7, 8}))
+
+	buf = make([]int, 4)
+	assert.Equal(4, q.popBatch(buf))
+	assert.Equal([]int{3, 4, 7, 8}, buf)
+
+	stop := make(chan struct{})
+	assert.True(q.tryPushAll([]int{1, 2, 3}))
+	assert.ErrorIs(q.pushAll(context.Background(), []int{4, 5}, stop, time.After(time.Millisecond)), ErrRecordDropped)
+
+	pushed := make(chan error, 1)
+	go func() {
+		pushed <- q.pushAll(context.Background(), []int{4, 5}, stop, nil)
+	}()
+
+	assert.Equal(1, q.popBatch(buf[:1]))
+	assert.NoError(<-pushed)
+	assert.Equal(4, q.popBatch(buf))
+	assert.Equal([]int{2, 3, 4, 5}, buf)
+}
+
+func TestRingQueue_PushBlocks(t *testing.T) {
+	t.Parallel()
+	assert := require.New(t)
+
+	q := newRingQueue[int](2)
+	stop := make(chan struct{})
+
+	assert.True(q.tryPush(1))
+	assert.True(q.tryPush(2))
+
+	timeout := time.After(time.Millisecond)
+	assert.ErrorIs(q.push(context.Background(), 3, stop, timeout), ErrRecordDropped)
+
+	pushed := make(chan error, 1)
+	go func() {
+		pushed <- q.push(context.Background(), 3, stop, nil)
+	}()
+
+	buf := make([]int, 1)
+	assert.Equal(1, q.popBatch(buf))
+	assert.NoError(<-pushed)
+
+	close(stop)
+	assert.ErrorIs(q.push(context.Background(), 4, stop, nil), ErrLoggerClosed)
+}
+
+func TestRingQueue_ConcurrentProducers(t *testing.T) {
+	t.Parallel()
+	assert := require.New(t)
+
+	const (
+		producers = 8
+		records   = 2000
+	)
+
+	q := newRingQueue[int](64)
+	errs := make(chan error, producers)
+
+	for p := 0; p < producers; p++ {
+		go func(p int) {
+			for i := 0; i < records; i++ {
+				if err := q.push(context.Background(), p*records+i, nil, nil); err != nil {
+					errs <- err
+					return
+				}
+			}
+			errs <- nil
+		}(p)
+	}
+
+	// Every producer's records come out in its own order
+	last := make([]int, producers)
+	for i := range last {
+		last[i] = -1
+	}
+
+	outOfOrder := 0
+	buf := make([]int, 16)
+
+	for received := 0; received < producers*records; {
+		n := q.popBatch(buf)
+		if n == 0 {
+			runtime.Gosched()
+		}
+
+		for _, v := range buf[:n] {
+			p, i := v/records, v%records
+			if i <= last[p] {
+				outOfOrder++
+			}
+			last[p] = i
+		}
+		received += n
+	}
+
+	for p := 0; p < producers; p++ {
+		assert.NoError(<-errs)
+	}
+
+	assert.Zero(outOfOrder)
+	assert.Equal(0, q.len())
+}
+
+func TestCachedLogging_RingBuffer(t *testing.T) {
+	t.Parallel()
+	assert := require.New(t)
+
+	const records = 10000
+
+	inner := &memoryLogger[int]{}
+	cached := NewCached[int](context.Background(), inner,
+		WithRingBuffer(),
+		WithWorkerPool(4),
+		WithQueueSize(16),
+		WithBufferSize(7),
+	)
+
+	wg := sync.WaitGroup{}
+	wg.Add(4)
+
+	for p := 0; p < 4; p++ {
+		go func(p int) {
+			defer wg.Done()
+			for i := p; i < records; i += 4 {
+				assert.NoError(cached.Log(i))
+			}
+		}(p)
+	}
+
+	wg.Wait()
+	assert.NoError(cached.Flush(context.Background()))
+	assert.Len(inner.records(), records)
+	assert.NoError(cached.Close())
+}
+
+func TestCachedLogging_RingBuffer_DropOldest(t *testing.T) {
+	t.Parallel()
+	assert := require.New(t)
+
+	inner := &memoryLogger[int]{release: make(chan struct{})}
+	cached := NewCached[int](context.Background(), inner,
+		WithRingBuffer(),
+		WithBufferSize(2),
+		WithQueueSize(4),
+		WithBackpressure(BackpressureDropOldest),
+	)
+
+	for i := 0; i < 100; i++ {
+		assert.NoError(cached.Log(i))
+	}
+
+	close(inner.release)
+	assert.NoError(cached.Close())
+
+	records := inner.records()
+	assert.Contains(records, 99)
+	assert.EqualValues(100, uint64(len(records))+cached.Dropped())
+}
+
+func benchmarkCachedLog(b *testing.B, mods ...ModifierCached) {
+	cached := NewCached[int](context.Background(), nopLogger[int]{}, append([]ModifierCached{WithWorkerPool(4)}, mods...)...)
+
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	b.RunParallel(func(pb *testing.PB) {
+		for i := 0; pb.Next(); i++ {
+			_ = cached.Log(i)
+		}
+	})
+
+	b.StopTimer()
+	_ = cached.Close()
+}
+
+func BenchmarkCachedLogging_Log_Channel(b *testing.B) {
+	benchmarkCachedLog(b)
+}
+
+func BenchmarkCachedLogging_Log_RingBuffer(b *testing.B) {
+	benchmarkCachedLog(b, WithRingBuffer())
+}
+
+type nopLogger[T any] struct{}
+
+func (nopLogger[T]) Log(T) error {
+	return nil
+}
+
+func (nopLogger[T]) LogMultiple([]T) error {
+	return nil
+}
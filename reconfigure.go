@@ -0,0 +1,198 @@
+package logger
+
+import (
+	"errors"
+	"fmt"
+)
+
+// ErrNotReconfigurable is returned by Reconfigure for an option that can
+// only be given to NewCached.
+var ErrNotReconfigurable = errors.New("logger: option can not be changed at runtime")
+
+// Reconfigure applies WithBufferSize, WithWorkerPool and WithRetryCount to
+// the running logger, without losing a record:
+//
+//   - the new buffer size applies to the batch each worker is filling, a
+//     batch already over it is written right away;
+//   - workers are added or retired one by one, a retired worker writes what
+//     it still has queued first. Lanes set up by WithLane keep their count;
+//   - the retry count applies to the next failed batch.
+//
+// Any other option fails with ErrNotReconfigurable and nothing is changed.
+// Changing the worker pool of an autoscaled logger is an error as well.
+func (l *CachedLogging[T]) Reconfigure(mods ...ModifierCached) error {
+	l.reconfigMu.Lock()
+	defer l.reconfigMu.Unlock()
+
+	l.mu.RLock()
+	config := CachedLoggingConfig{
+		bufferSize: l.limit.count,
+		workers:    l.poolSize,
+		retryCount: int(l.retries.count.Load()),
+	}
+	closed := l.closed
+	l.mu.RUnlock()
+
+	if closed {
+		return ErrLoggerClosed
+	}
+
+	current := config
+
+	for _, mod := range mods {
+		mod(&config)
+	}
+
+	if option := config.fixedOption(); option != "" {
+		return fmt.Errorf("%w: %s", ErrNotReconfigurable, option)
+	}
+
+	if config.workers != current.workers {
+		if l.autoscaled {
+			return fmt.Errorf("%w: WithWorkerPool, the workers are autoscaled", ErrNotReconfigurable)
+		}
+
+		// A retired worker writes what it holds
+		if l.paused.Load() {
+			return fmt.Errorf("%w: WithWorkerPool while paused", ErrNotReconfigurable)
+		}
+
+		if config.workers <= 0 {
+			return fmt.Errorf("logger: worker pool size must be positive, got %d", config.workers)
+		}
+	}
+
+	// Same defaults as NewCached
+	if config.retryCount <= 0 {
+		config.retryCount = 1
+	}
+
+	if config.bufferSize <= 0 && (l.limit.bytes <= 0 || l.limit.size == nil) {
+		config.bufferSize = defaultBufferSize
+	}
+
+	l.retries.count.Store(int64(config.retryCount))
+
+	if config.bufferSize != current.bufferSize {
+		if err := l.setLimit(config.bufferSize); err != nil {
+			return err
+		}
+	}
+
+	if config.workers != current.workers {
+		l.resize(config.workers)
+	}
+
+	return nil
+}
+
+// setLimit hands the new batch limit to every worker. Workers started
+// later pick it up from l.limit.
+func (l *CachedLogging[T]) setLimit(count int) error {
+	l.mu.Lock()
+	l.limit.count = count
+	limit := l.limit
+	l.mu.Unlock()
+
+	for _, w := range l.workers() {
+		select {
+		case w.limits <- limit:
+		case <-w.done:
+		case <-l.stopping:
+			return ErrLoggerClosed
+		}
+	}
+
+	return nil
+}
+
+// resize adds or retires workers until every lane that is not pinned has
+// size of them.
+func (l *CachedLogging[T]) resize(size int) {
+	l.mu.Lock()
+	l.poolSize = size
+	l.mu.Unlock()
+
+	for _, ln := range l.lanes {
+		if !ln.pinned && !l.resizeLane(ln, size) {
+			return
+		}
+	}
+}
+
+// resizeLane returns false when the logger was closed in the meantime,
+// Shutdown takes over the workers then.
+func (l *CachedLogging[T]) resizeLane(ln *lane[T], size int) bool {
+	for {
+		l.mu.RLock()
+		n := len(ln.workers)
+		l.mu.RUnlock()
+
+		switch {
+		case n < size:
+			l.addWorker(ln)
+		case n > size:
+			l.retireWorker(ln)
+		default:
+			return true
+		}
+
+		select {
+		case <-l.stopping:
+			return false
+		default:
+		}
+	}
+}
+
+// fixedOption names the first option set that only NewCached accepts.
+func (c *CachedLoggingConfig) fixedOption() string {
+	switch {
+	case c.enrich != nil:
+		return "WithCachedEnricher"
+	case c.deadLetter != nil:
+		return "WithDeadLetter"
+	case c.fallback != nil:
+		return "WithShutdownFallback"
+	case c.shardKey != nil:
+		return "WithShardKey"
+	case c.batchSize != nil, c.maxBatchBytes != 0:
+		return "WithMaxBatchBytes"
+	case c.classify != nil:
+		return "WithPriority"
+	case c.lanes != nil:
+		return "WithLane"
+	case c.spoolCodec != nil, c.spoolDir != "":
+		return "WithSpool"
+	case c.spoolSize != 0:
+		return "WithSpoolSegmentSize"
+	case c.pauseLimit != PauseLimit{}:
+		return "WithPauseLimit"
+	case c.atomicBatches:
+		return "WithAtomicBatches"
+	case c.ringBuffer:
+		return "WithRingBuffer"
+	case c.autoscale != nil:
+		return "WithAutoscaling"
+	case c.expvarName != "":
+		return "WithExpvar"
+	case c.logger != nil:
+		return "WithCachedErrorLogger"
+	case c.clock != nil:
+		return "WithClock"
+	case c.retryPolicy != RetryPolicy{}:
+		return "WithRetryPolicy"
+	case c.retryConcurrency != 0:
+		return "WithRetryConcurrency"
+	case c.queueSize != 0:
+		return "WithQueueSize"
+	case c.blockTimeout != 0:
+		return "WithBlockTimeout"
+	case c.backpressure != BackpressureBlock:
+		return "WithBackpressure"
+	case c.flushInterval != 0:
+		return "WithFlushInterval"
+	default:
+		return ""
+	}
+}
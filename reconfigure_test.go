@@ -0,0 +1,154 @@
+package logger
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestCachedLogging_Reconfigure_BufferSize(t *testing.T) {
+	t.Parallel()
+	assert := require.New(t)
+
+	inner := &memoryLogger[int]{}
+	cached := NewCached[int](context.Background(), inner, WithBufferSize(100))
+
+	for i := 0; i < 3; i++ {
+		assert.NoError(cached.Log(i))
+	}
+
+	assert.Eventually(func() bool {
+		return cached.lanes[0].workers[0].queue.len() == 0
+	}, time.Second, time.Millisecond)
+
+	// The batch is over the new limit, it is written right away
+	assert.NoError(cached.Reconfigure(WithBufferSize(2)))
+	assert.Eventually(func() bool {
+		return len(inner.records()) == 3
+	}, time.Second, time.Millisecond)
+
+	assert.NoError(cached.Log(3))
+	assert.NoError(cached.Log(4))
+	assert.Eventually(func() bool {
+		return len(inner.records()) == 5
+	}, time.Second, time.Millisecond)
+
+	assert.NoError(cached.Close())
+	assert.Equal([][]int{{0, 1, 2}, {3, 4}}, inner.batches)
+}
+
+func TestCachedLogging_Reconfigure_Workers(t *testing.T) {
+	t.Parallel()
+	assert := require.New(t)
+
+	inner := &memoryLogger[int]{}
+	cached := NewCached[int](context.Background(), inner, WithBufferSize(100))
+
+	assert.NoError(cached.Reconfigure(WithWorkerPool(3)))
+	assert.Len(cached.lanes[0].workers, 3)
+
+	for i := 0; i < 10; i++ {
+		assert.NoError(cached.Log(i))
+	}
+
+	// The retired workers write what they hold before they go
+	assert.NoError(cached.Reconfigure(WithWorkerPool(1)))
+	assert.Len(cached.lanes[0].workers, 1)
+	assert.NotEmpty(inner.records())
+	assert.Len(cached.Stats().Workers, 1)
+
+	// Workers started later use the current limit
+	assert.NoError(cached.Reconfigure(WithBufferSize(1), WithWorkerPool(2)))
+	assert.Equal(1, cached.lanes[0].workers[1].limit.count)
+
+	assert.NoError(cached.Close())
+	assert.ElementsMatch([]int{0, 1, 2, 3, 4, 5, 6, 7, 8, 9}, inner.records())
+}
+
+func TestCachedLogging_Reconfigure_RetryCount(t *testing.T) {
+	t.Parallel()
+	assert := require.New(t)
+
+	cached := NewCached[int](context.Background(), &memoryLogger[int]{})
+
+	assert.NoError(cached.Reconfigure(WithRetryCount(5)))
+	assert.EqualValues(5, cached.retries.count.Load())
+
+	assert.NoError(cached.Close())
+}
+
+func TestCachedLogging_Reconfigure_Rejected(t *testing.T) {
+	t.Parallel()
+	assert := require.New(t)
+
+	cached := NewCached[int](context.Background(), &memoryLogger[int]{}, WithBufferSize(10))
+
+	assert.ErrorIs(cached.Reconfigure(WithBufferSize(5), WithQueueSize(10)), ErrNotReconfigurable)
+	assert.Error(cached.Reconfigure(WithWorkerPool(0)))
+
+	// Nothing was applied
+	assert.Equal(10, cached.limit.count)
+
+	assert.NoError(cached.Close())
+	assert.ErrorIs(cached.Reconfigure(WithBufferSize(5)), ErrLoggerClosed)
+
+	autoscaled := NewCached[int](context.Background(), &memoryLogger[int]{}, WithAutoscaling(DefaultAutoscalePolicy))
+
+	assert.ErrorIs(autoscaled.Reconfigure(WithWorkerPool(4)), ErrNotReconfigurable)
+	assert.NoError(autoscaled.Close())
+}
+
+// holdingLogger blocks the batches holding the record -1 until release is
+// closed.
+type holdingLogger struct {
+	memoryLogger[int]
+	hold chan struct{}
+}
+
+func (l *holdingLogger) LogMultiple(data []int) error {
+	for _, record := range data {
+		if record == -1 {
+			<-l.hold
+		}
+	}
+
+	return l.memoryLogger.LogMultiple(data)
+}
+
+func TestCachedLogging_Flush_DrainingWorker(t *testing.T) {
+	t.Parallel()
+	assert := require.New(t)
+
+	inner := &holdingLogger{hold: make(chan struct{})}
+	cached := NewCached[int](context.Background(), inner, WithBufferSize(100), WithWorkerPool(2))
+
+	// Round-robin, the first record goes to the last worker
+	assert.NoError(cached.Log(-1))
+	assert.NoError(cached.Log(2))
+
+	resized := make(chan error, 1)
+	go func() {
+		resized <- cached.Reconfigure(WithWorkerPool(1))
+	}()
+
+	assert.Eventually(func() bool {
+		cached.mu.RLock()
+		defer cached.mu.RUnlock()
+
+		return len(cached.draining) == 1
+	}, time.Second, time.Millisecond)
+
+	// The retired worker still writes its record
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	assert.ErrorIs(cached.Flush(ctx), context.DeadlineExceeded)
+
+	close(inner.hold)
+	assert.NoError(<-resized)
+	assert.NoError(cached.Flush(context.Background()))
+	assert.ElementsMatch([]int{-1, 2}, inner.records())
+
+	assert.NoError(cached.Close())
+}
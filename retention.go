@@ -0,0 +1,230 @@
+package logger
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultRetentionInterval is how often the retention runs when the policy
+// does not set an interval.
+const DefaultRetentionInterval = 10 * time.Minute
+
+// RetentionPolicy selects the files to remove. A file goes once it is older
+// than MaxAge, by modification time, and the oldest go first while all the
+// files together take more than MaxBytes. Zero disables either limit.
+type RetentionPolicy struct {
+	// Pattern is a filepath.Match glob, like /var/log/app/events-*.ndjson*.
+	// The temporary files of the compression are never removed.
+	Pattern  string
+	MaxAge   time.Duration
+	MaxBytes int64
+	// Interval is the time between two runs, DefaultRetentionInterval when
+	// zero and no periodic run when negative
+	Interval time.Duration
+	// DryRun only reports the files that would be removed, through the
+	// error logger
+	DryRun bool
+}
+
+// Retention removes the files of a RetentionPolicy on a goroutine of its
+// own, every Interval and whenever Trigger is called.
+type Retention struct {
+	policy  RetentionPolicy
+	error   Error
+	clock   Clock
+	mu      sync.Mutex
+	trigger chan struct{}
+	stop    chan struct{}
+	done    chan struct{}
+	once    sync.Once
+	// keep protects the files that are still written, the active file of a
+	// FileLogger
+	keep func(path string) bool
+}
+
+// WithRetention removes the old files of a FileLogger, see RetentionPolicy.
+// It runs after every rotation of WithSizeRotation and WithTimeRotation as
+// well, and only periodically without them. The active file is never
+// removed. The time comes from WithFileClock.
+func WithRetention(policy RetentionPolicy) FileModifier {
+	return func(c *FileConfig) {
+		c.retention = &policy
+	}
+}
+
+// NewRetention starts the retention, clock is the system clock when nil.
+// Close stops it.
+func NewRetention(policy RetentionPolicy, errorLog Error, clock Clock) *Retention {
+	return newRetention(policy, errorLog, clock, nil)
+}
+
+func newRetention(policy RetentionPolicy, errorLog Error, clock Clock, keep func(string) bool) *Retention {
+	if clock == nil {
+		clock = systemClock{}
+	}
+
+	if policy.Interval == 0 {
+		policy.Interval = DefaultRetentionInterval
+	}
+
+	r := &Retention{
+		policy:  policy,
+		error:   errorLog,
+		clock:   clock,
+		trigger: make(chan struct{}, 1),
+		stop:    make(chan struct{}),
+		done:    make(chan struct{}),
+		keep:    keep,
+	}
+
+	go r.run()
+
+	return r
+}
+
+// Trigger runs the retention as soon as possible, it never blocks.
+func (r *Retention) Trigger() {
+	select {
+	case r.trigger <- struct{}{}:
+	default:
+	}
+}
+
+// Close stops the retention, a run in progress is finished first.
+func (r *Retention) Close() error {
+	r.once.Do(func() {
+		close(r.stop)
+	})
+
+	<-r.done
+
+	return nil
+}
+
+func (r *Retention) run() {
+	defer close(r.done)
+
+	var tick <-chan time.Time
+
+	if r.policy.Interval > 0 {
+		ticker := r.clock.NewTicker(r.policy.Interval)
+		defer ticker.Stop()
+
+		tick = ticker.C()
+	}
+
+	for {
+		// Errors are reported by Apply
+		_, _ = r.Apply()
+
+		select {
+		case <-r.stop:
+			return
+		case <-tick:
+		case <-r.trigger:
+		}
+	}
+}
+
+type retainedFile struct {
+	path    string
+	size    int64
+	modTime time.Time
+}
+
+// Apply runs the retention once and returns the files it removed, or would
+// remove in a dry run.
+func (r *Retention) Apply() ([]string, error) {
+	// A trigger and the ticker must not remove the same files twice
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	files, err := r.files()
+	if err != nil {
+		if r.error != nil {
+			r.error.Print(failedToApplyRetention, r.policy.Pattern, err)
+		}
+		return nil, err
+	}
+
+	// Oldest first, the budget removes them in this order
+	sort.Slice(files, func(i, j int) bool {
+		return files[i].modTime.Before(files[j].modTime)
+	})
+
+	var total int64
+	for _, file := range files {
+		total += file.size
+	}
+
+	now := r.clock.Now()
+	removed := make([]string, 0)
+
+	// The first failure is returned, every one is reported
+	var removeErr error
+
+	for _, file := range files {
+		reason := ""
+
+		switch {
+		case r.policy.MaxAge > 0 && now.Sub(file.modTime) > r.policy.MaxAge:
+			reason = "age"
+		case r.policy.MaxBytes > 0 && total > r.policy.MaxBytes:
+			reason = "size"
+		default:
+			continue
+		}
+
+		if r.keep != nil && r.keep(file.path) {
+			continue
+		}
+
+		if r.policy.DryRun {
+			if r.error != nil {
+				r.error.Print(retentionDryRun, file.path, reason, file.size)
+			}
+		} else if err := os.Remove(file.path); err != nil && !os.IsNotExist(err) {
+			if r.error != nil {
+				r.error.Print(failedToRemoveTheFile, file.path, err)
+			}
+			if removeErr == nil {
+				removeErr = err
+			}
+			continue
+		}
+
+		total -= file.size
+		removed = append(removed, file.path)
+	}
+
+	return removed, removeErr
+}
+
+func (r *Retention) files() ([]retainedFile, error) {
+	matches, err := filepath.Glob(r.policy.Pattern)
+	if err != nil {
+		return nil, err
+	}
+
+	files := make([]retainedFile, 0, len(matches))
+
+	for _, path := range matches {
+		if strings.HasSuffix(path, ".tmp") {
+			continue
+		}
+
+		info, err := os.Stat(path)
+		if err != nil || !info.Mode().IsRegular() {
+			// Gone since the glob, or a directory
+			continue
+		}
+
+		files = append(files, retainedFile{path: path, size: info.Size(), modTime: info.ModTime()})
+	}
+
+	return files, nil
+}
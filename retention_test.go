@@ -0,0 +1,188 @@
+package logger
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/nano-interactive/go-logger/__mocks__/error_log"
+	realSerializer "github.com/nano-interactive/go-logger/serializer"
+)
+
+// writeAged creates a file of size bytes, modified age before now.
+func writeAged(t *testing.T, path string, size int, now time.Time, age time.Duration) {
+	t.Helper()
+
+	require.NoError(t, os.WriteFile(path, make([]byte, size), 0o644))
+	require.NoError(t, os.Chtimes(path, now.Add(-age), now.Add(-age)))
+}
+
+func TestRetention_Apply(t *testing.T) {
+	t.Parallel()
+	assert := require.New(t)
+
+	dir := t.TempDir()
+	clock := newFakeClock()
+	now := clock.Now()
+
+	writeAged(t, filepath.Join(dir, "a.log"), 10, now, 72*time.Hour)
+	writeAged(t, filepath.Join(dir, "b.log"), 10, now, 48*time.Hour)
+	writeAged(t, filepath.Join(dir, "c.log"), 10, now, time.Hour)
+	writeAged(t, filepath.Join(dir, "d.log"), 10, now, 0)
+	writeAged(t, filepath.Join(dir, "e.txt"), 10, now, 72*time.Hour)
+	writeAged(t, filepath.Join(dir, "f.log.gz.tmp"), 10, now, 72*time.Hour)
+
+	retention := NewRetention(RetentionPolicy{
+		Pattern:  filepath.Join(dir, "*.log*"),
+		MaxAge:   36 * time.Hour,
+		MaxBytes: 15,
+		Interval: -1,
+	}, nil, clock)
+	assert.NoError(retention.Close())
+
+	// Already removed by the first run of the goroutine
+	removed, err := retention.Apply()
+	assert.NoError(err)
+	assert.Empty(removed)
+
+	for _, name := range []string{"a.log", "b.log", "c.log"} {
+		assert.NoFileExists(filepath.Join(dir, name))
+	}
+
+	for _, name := range []string{"d.log", "e.txt", "f.log.gz.tmp"} {
+		assert.FileExists(filepath.Join(dir, name))
+	}
+}
+
+func TestRetention_DryRun(t *testing.T) {
+	t.Parallel()
+	assert := require.New(t)
+
+	dir := t.TempDir()
+	clock := newFakeClock()
+	now := clock.Now()
+	errLog := error_log.NewMockLogger()
+
+	writeAged(t, filepath.Join(dir, "a.log"), 10, now, 72*time.Hour)
+	writeAged(t, filepath.Join(dir, "b.log"), 10, now, time.Hour)
+	writeAged(t, filepath.Join(dir, "c.log"), 10, now, 0)
+
+	retention := NewRetention(RetentionPolicy{
+		Pattern:  filepath.Join(dir, "*.log"),
+		MaxAge:   36 * time.Hour,
+		MaxBytes: 10,
+		Interval: -1,
+		DryRun:   true,
+	}, errLog, clock)
+	assert.NoError(retention.Close())
+
+	removed, err := retention.Apply()
+	assert.NoError(err)
+	assert.Equal([]string{filepath.Join(dir, "a.log"), filepath.Join(dir, "b.log")}, removed)
+
+	for _, name := range []string{"a.log", "b.log", "c.log"} {
+		assert.FileExists(filepath.Join(dir, name))
+	}
+
+	// Reported by both runs
+	assert.Equal([]string{
+		`{"msg":"retention would remove the file ` + filepath.Join(dir, "a.log") + `","reason":"age","size":10}`,
+		`{"msg":"retention would remove the file ` + filepath.Join(dir, "b.log") + `","reason":"size","size":10}`,
+		`{"msg":"retention would remove the file ` + filepath.Join(dir, "a.log") + `","reason":"age","size":10}`,
+		`{"msg":"retention would remove the file ` + filepath.Join(dir, "b.log") + `","reason":"size","size":10}`,
+	}, errLog.Buffer)
+}
+
+func TestRetention_Periodic(t *testing.T) {
+	t.Parallel()
+	assert := require.New(t)
+
+	dir := t.TempDir()
+	clock := newFakeClock()
+	path := filepath.Join(dir, "a.log")
+
+	writeAged(t, path, 10, clock.Now(), 0)
+
+	retention := NewRetention(RetentionPolicy{
+		Pattern:  filepath.Join(dir, "*.log"),
+		MaxAge:   30 * time.Minute,
+		Interval: time.Hour,
+	}, nil, clock)
+
+	clock.WaitForTickers(1)
+	clock.Advance(time.Hour)
+
+	assert.Eventually(func() bool {
+		_, err := os.Stat(path)
+		return os.IsNotExist(err)
+	}, time.Second, time.Millisecond)
+
+	assert.NoError(retention.Close())
+}
+
+func TestFileLogger_Retention(t *testing.T) {
+	t.Parallel()
+	assert := require.New(t)
+
+	dir := t.TempDir()
+	path := filepath.Join(dir, "test.json")
+
+	// Every record is 13 bytes, two fit in a file
+	fileLogger := NewFileLoggerWithOptions[logData](path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644, realSerializer.NewJson[logData](),
+		WithSizeRotation(SizeRotation{MaxSize: 30}),
+		WithRetention(RetentionPolicy{Pattern: path + "*", MaxBytes: 40}),
+	)
+
+	for _, name := range []string{"a", "b", "c", "d", "e", "f", "g"} {
+		assert.NoError(fileLogger.Log(logData{Name: name}))
+	}
+
+	// Triggered by the rotations, the active file always stays
+	assert.Eventually(func() bool {
+		matches, err := filepath.Glob(path + "*")
+		assert.NoError(err)
+
+		var total int64
+		for _, match := range matches {
+			if info, err := os.Stat(match); err == nil {
+				total += info.Size()
+			}
+		}
+
+		return total <= 40
+	}, time.Second, time.Millisecond)
+
+	assert.NoError(fileLogger.Close())
+	assert.Equal([]string{`{"name":"g"}`}, readLines(t, path))
+}
+
+func TestFileLogger_Retention_WithoutHandle(t *testing.T) {
+	t.Parallel()
+	assert := require.New(t)
+
+	dir := t.TempDir()
+	path := filepath.Join(dir, "test.json")
+	old := filepath.Join(dir, "test.json.1")
+	clock := newFakeClock()
+
+	writeAged(t, old, 10, clock.Now(), 48*time.Hour)
+	writeAged(t, path, 10, clock.Now(), 48*time.Hour)
+
+	fileLogger := NewFileLoggerWithOptions[logData](path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644, realSerializer.NewJson[logData](),
+		WithRetention(RetentionPolicy{Pattern: path + "*", MaxAge: time.Hour}),
+		WithFileClock(clock),
+	)
+
+	// The first run starts right away, the file written to stays
+	assert.Eventually(func() bool {
+		_, err := os.Stat(old)
+		return os.IsNotExist(err)
+	}, time.Second, time.Millisecond)
+
+	assert.NoError(fileLogger.Log(logData{Name: "a"}))
+	assert.NoError(fileLogger.Close())
+	assert.FileExists(path)
+}
@@ -0,0 +1,322 @@
+package logger
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+	"runtime/debug"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// RetryPolicy controls how a worker retries a batch the inner logger
+// rejected. The number of attempts is set by WithRetryCount.
+type RetryPolicy struct {
+	// InitialInterval is the delay before the first retry
+	InitialInterval time.Duration
+	// MaxInterval caps the delay between two attempts
+	MaxInterval time.Duration
+	// Multiplier grows the delay after every attempt
+	Multiplier float64
+	// Jitter randomizes each delay by ±Jitter (0.2 means ±20%)
+	Jitter float64
+	// MaxElapsedTime stops retrying once the next attempt would start later
+	// than this after the first failure. Zero means no limit.
+	MaxElapsedTime time.Duration
+}
+
+var DefaultRetryPolicy = RetryPolicy{
+	InitialInterval: 100 * time.Millisecond,
+	MaxInterval:     10 * time.Second,
+	Multiplier:      2,
+	Jitter:          0.2,
+}
+
+// delay returns the wait before the given attempt, starting at zero.
+func (p RetryPolicy) delay(attempt int) time.Duration {
+	multiplier := p.Multiplier
+	if multiplier < 1 {
+		multiplier = 1
+	}
+
+	delay := float64(p.InitialInterval) * math.Pow(multiplier, float64(attempt))
+
+	if p.MaxInterval > 0 && delay > float64(p.MaxInterval) {
+		delay = float64(p.MaxInterval)
+	}
+
+	if p.Jitter > 0 {
+		delay += delay * p.Jitter * (2*rand.Float64() - 1)
+	}
+
+	if delay <= 0 {
+		return 0
+	}
+
+	return time.Duration(delay)
+}
+
+// retryQueue owns the batches rejected by the inner logger of one
+// CachedLogging. Every batch is copied into a buffer from the queue's own
+// pool, so the workers can reuse their cache right away and loggers with
+// different record types never share memory.
+type retryQueue[T any] struct {
+	log        Log[T]
+	deadLetter Log[T]
+	error      Error
+	clock      Clock
+	policy     RetryPolicy
+	// count can be changed by Reconfigure while batches are retried
+	count atomic.Int64
+
+	// slots bounds the number of batches retried at the same time
+	slots chan struct{}
+	pool  sync.Pool
+
+	mu       sync.Mutex
+	idle     *sync.Cond
+	inflight int
+	lastErr  error
+
+	// waiting holds the batches sleeping between two attempts, the only
+	// ones abort can take back without racing a write
+	waiting map[*[]T]struct{}
+	// records counts the records of the batches being retried
+	records int
+	aborted bool
+	abortCh chan struct{}
+}
+
+func newRetryQueue[T any](log, deadLetter Log[T], errorLog Error, clock Clock, policy RetryPolicy, count, concurrency int) *retryQueue[T] {
+	q := &retryQueue[T]{
+		log:        log,
+		deadLetter: deadLetter,
+		error:      errorLog,
+		clock:      clock,
+		policy:     policy,
+		slots:      make(chan struct{}, concurrency),
+		waiting:    make(map[*[]T]struct{}),
+		abortCh:    make(chan struct{}),
+		pool: sync.Pool{
+			New: func() any {
+				return new([]T)
+			},
+		},
+	}
+
+	q.idle = sync.NewCond(&q.mu)
+	q.count.Store(int64(count))
+
+	return q
+}
+
+// submit copies the batch and retries it in the background. When all
+// slots are taken, it blocks the calling worker, which in turn lets the
+// backpressure policy act on the producers. cause is the error of the
+// failed write, reported when no attempt is made. done, when set, runs
+// once the batch was delivered, dead-lettered or discarded. stats, when
+// set, counts the attempts.
+func (q *retryQueue[T]) submit(batch []T, cause error, done func(), stats *workerStats) {
+	go q.run(q.acquire(batch), cause, done, stats)
+}
+
+// retryNow retries the batch on the calling goroutine, so the batches that
+// follow can not overtake it.
+func (q *retryQueue[T]) retryNow(batch []T, cause error, done func(), stats *workerStats) {
+	q.run(q.acquire(batch), cause, done, stats)
+}
+
+func (q *retryQueue[T]) acquire(batch []T) *[]T {
+	q.slots <- struct{}{}
+
+	buf := q.pool.Get().(*[]T)
+	*buf = append((*buf)[:0], batch...)
+
+	q.mu.Lock()
+	q.inflight++
+	q.records += len(batch)
+	q.mu.Unlock()
+
+	return buf
+}
+
+func (q *retryQueue[T]) run(buf *[]T, cause error, done func(), stats *workerStats) {
+	var err error
+
+	records := len(*buf)
+
+	// Deferred, so a panicking ordered retry does not keep the slot and
+	// leave Flush and Close waiting for it
+	defer func() {
+		<-q.slots
+
+		q.mu.Lock()
+		if err != nil {
+			q.lastErr = err
+		}
+		q.inflight--
+		q.records -= records
+		if q.inflight == 0 {
+			q.idle.Broadcast()
+		}
+		q.mu.Unlock()
+	}()
+
+	// The retries of an unordered worker run on their own goroutine, out of
+	// reach of the worker's recover
+	defer func() {
+		r := recover()
+		if r == nil {
+			return
+		}
+
+		err = fmt.Errorf("logger: retry panicked: %v", r)
+		q.error.Print(retryPanicked, len(*buf), r, debug.Stack())
+
+		// Discarded, the spool must not replay it either
+		if done != nil {
+			done()
+		}
+	}()
+
+	abandoned, err := q.retry(buf, cause, stats)
+
+	// abort owns the batch now
+	if !abandoned {
+		if done != nil {
+			done()
+		}
+
+		// Drop the references so the records can be collected
+		var zero T
+		for i := range *buf {
+			(*buf)[i] = zero
+		}
+		*buf = (*buf)[:0]
+		q.pool.Put(buf)
+	}
+}
+
+// wait blocks until no batch is being retried.
+func (q *retryQueue[T]) wait() {
+	q.mu.Lock()
+	for q.inflight > 0 {
+		q.idle.Wait()
+	}
+	q.mu.Unlock()
+}
+
+// takeErr returns the last undelivered batch error and clears it.
+func (q *retryQueue[T]) takeErr() error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	err := q.lastErr
+	q.lastErr = nil
+
+	return err
+}
+
+// abort stops every retry that waits for its next attempt and returns the
+// batches it took over, along with the number of records in the retries
+// in the middle of a write. Those are left alone; if their write fails,
+// backoff discards them.
+func (q *retryQueue[T]) abort() ([][]T, int) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if q.aborted {
+		return nil, 0
+	}
+
+	q.aborted = true
+	close(q.abortCh)
+
+	batches := make([][]T, 0, len(q.waiting))
+
+	writing := q.records
+
+	for buf := range q.waiting {
+		batches = append(batches, *buf)
+		writing -= len(*buf)
+		delete(q.waiting, buf)
+	}
+
+	return batches, writing
+}
+
+// backoff sleeps before the next attempt. It returns false when abort took
+// the batch over in the meantime.
+func (q *retryQueue[T]) backoff(buf *[]T, delay time.Duration) bool {
+	q.mu.Lock()
+	if q.aborted {
+		q.mu.Unlock()
+		q.error.Print(batchDiscarded, len(*buf), ErrLoggerClosed)
+		return false
+	}
+	q.waiting[buf] = struct{}{}
+	q.mu.Unlock()
+
+	select {
+	case <-q.clock.After(delay):
+	case <-q.abortCh:
+	}
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	_, owned := q.waiting[buf]
+	delete(q.waiting, buf)
+
+	return owned
+}
+
+// retry writes the batch again following the retry policy and hands it to
+// the dead-letter logger when every attempt failed. The error is cause
+// until an attempt is made.
+func (q *retryQueue[T]) retry(buf *[]T, cause error, stats *workerStats) (abandoned bool, err error) {
+	batch := *buf
+	err = cause
+	start := q.clock.Now()
+
+	count := int(q.count.Load())
+
+	for attempt := 0; attempt < count; attempt++ {
+		delay := q.policy.delay(attempt)
+
+		if q.policy.MaxElapsedTime > 0 && q.clock.Now().Add(delay).Sub(start) > q.policy.MaxElapsedTime {
+			break
+		}
+
+		if delay > 0 && !q.backoff(buf, delay) {
+			return true, ErrLoggerClosed
+		}
+
+		if stats != nil {
+			stats.retries.Add(1)
+		}
+
+		var n int
+		n, err = writeBatch(q.log, batch)
+		stats.written(len(batch), n, err, q.clock)
+
+		if err == nil {
+			return false, nil
+		}
+	}
+
+	if q.deadLetter == nil {
+		q.error.Print(batchDiscarded, len(batch), err)
+		return false, err
+	}
+
+	if dlErr := q.deadLetter.LogMultiple(batch); dlErr != nil {
+		q.error.Print(failedToDeadLetter, len(batch), dlErr)
+		return false, err
+	}
+
+	q.error.Print(batchDeadLettered, len(batch), err)
+
+	return false, err
+}
@@ -0,0 +1,317 @@
+package logger
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/nano-interactive/go-logger/__mocks__/error_log"
+)
+
+// flakyLogger rejects the first failures batches.
+type flakyLogger[T any] struct {
+	memoryLogger[T]
+	failures int32
+	attempts atomic.Int32
+}
+
+func (f *flakyLogger[T]) LogMultiple(data []T) error {
+	if f.attempts.Add(1) <= f.failures {
+		return errors.New("sink is down")
+	}
+
+	return f.memoryLogger.LogMultiple(data)
+}
+
+func TestRetryPolicy_Delay(t *testing.T) {
+	t.Parallel()
+	assert := require.New(t)
+
+	policy := RetryPolicy{
+		InitialInterval: 100 * time.Millisecond,
+		MaxInterval:     time.Second,
+		Multiplier:      2,
+	}
+
+	assert.Equal(100*time.Millisecond, policy.delay(0))
+	assert.Equal(200*time.Millisecond, policy.delay(1))
+	assert.Equal(400*time.Millisecond, policy.delay(2))
+	assert.Equal(time.Second, policy.delay(10))
+
+	policy.Jitter = 0.5
+
+	for i := 0; i < 100; i++ {
+		delay := policy.delay(1)
+		assert.GreaterOrEqual(delay, 100*time.Millisecond)
+		assert.LessOrEqual(delay, 300*time.Millisecond)
+	}
+}
+
+func TestLogWorker_Retry_Backoff(t *testing.T) {
+	t.Parallel()
+	assert := require.New(t)
+
+	clock := newFakeClock()
+	inner := &flakyLogger[int]{failures: 2}
+	cached := NewCached[int](context.Background(), inner,
+		WithBufferSize(2),
+		WithRetryCount(5),
+		WithClock(clock),
+		WithRetryPolicy(RetryPolicy{InitialInterval: time.Second, Multiplier: 2}),
+	)
+
+	assert.NoError(cached.LogMultiple([]int{1, 2}))
+
+	// First write failed, the retry waits for the clock
+	assert.Eventually(func() bool { return clock.Timers() == 1 }, time.Second, time.Millisecond)
+	assert.EqualValues(1, inner.attempts.Load())
+
+	clock.Advance(time.Second)
+	assert.Eventually(func() bool { return clock.Timers() == 1 }, time.Second, time.Millisecond)
+	assert.EqualValues(2, inner.attempts.Load())
+
+	// The second retry waits twice as long
+	clock.Advance(time.Second)
+	assert.EqualValues(2, inner.attempts.Load())
+	clock.Advance(time.Second)
+
+	assert.NoError(cached.Flush(context.Background()))
+	assert.EqualValues(3, inner.attempts.Load())
+	assert.Equal([]int{1, 2}, inner.records())
+	assert.NoError(cached.Close())
+}
+
+func TestLogWorker_Retry_MaxElapsedTime(t *testing.T) {
+	t.Parallel()
+	assert := require.New(t)
+
+	clock := newFakeClock()
+	inner := &flakyLogger[int]{failures: 100}
+	cached := NewCached[int](context.Background(), inner,
+		WithBufferSize(1),
+		WithRetryCount(10),
+		WithClock(clock),
+		WithRetryPolicy(RetryPolicy{
+			InitialInterval: time.Second,
+			Multiplier:      2,
+			MaxElapsedTime:  2 * time.Second,
+		}),
+	)
+
+	assert.NoError(cached.Log(1))
+	assert.Eventually(func() bool { return clock.Timers() == 1 }, time.Second, time.Millisecond)
+	clock.Advance(time.Second)
+
+	// The next delay of 2s would end after MaxElapsedTime
+	assert.EqualError(cached.Flush(context.Background()), "sink is down")
+	assert.EqualValues(2, inner.attempts.Load())
+	assert.NoError(cached.Close())
+}
+
+func TestLogWorker_DeadLetter(t *testing.T) {
+	t.Parallel()
+	assert := require.New(t)
+
+	errLog := error_log.NewMockLogger()
+	inner := &flakyLogger[int]{failures: 100}
+	deadLetter := &memoryLogger[int]{}
+
+	cached := NewCached[int](context.Background(), inner,
+		WithBufferSize(3),
+		WithRetryCount(2),
+		WithRetryPolicy(RetryPolicy{}),
+		WithDeadLetter[int](deadLetter),
+		WithCachedErrorLogger(errLog),
+	)
+
+	assert.NoError(cached.LogMultiple([]int{1, 2, 3}))
+	assert.EqualError(cached.Flush(context.Background()), "sink is down")
+
+	assert.EqualValues(3, inner.attempts.Load())
+	assert.Equal([]int{1, 2, 3}, deadLetter.records())
+	assert.Equal([]string{fmt.Sprintf(batchDeadLettered, 3, "sink is down")}, errLog.Buffer)
+	assert.NoError(cached.Close())
+}
+
+func TestLogWorker_Retry_MaxElapsedBeforeFirstAttempt(t *testing.T) {
+	t.Parallel()
+	assert := require.New(t)
+
+	errLog := error_log.NewMockLogger()
+	inner := &flakyLogger[int]{failures: 1}
+	cached := NewCached[int](context.Background(), inner,
+		WithBufferSize(1),
+		WithCachedErrorLogger(errLog),
+		WithRetryCount(3),
+		WithRetryPolicy(RetryPolicy{InitialInterval: time.Second, MaxElapsedTime: time.Millisecond}),
+	)
+
+	assert.NoError(cached.Log(1))
+
+	// No retry is made, the batch is discarded with the error of the write
+	assert.EqualError(cached.Flush(context.Background()), "sink is down")
+	assert.EqualValues(1, inner.attempts.Load())
+	assert.Equal([]string{fmt.Sprintf(batchDiscarded, 1, "sink is down")}, errLog.Buffer)
+
+	assert.NoError(cached.Close())
+}
+
+func TestLogWorker_DeadLetter_WrongType(t *testing.T) {
+	t.Parallel()
+	assert := require.New(t)
+
+	errLog := error_log.NewMockLogger()
+	inner := &memoryLogger[int]{}
+
+	// Reported and ignored, as if the option was not given
+	cached := NewCached[int](context.Background(), inner,
+		WithCachedErrorLogger(errLog),
+		WithDeadLetter[string](&memoryLogger[string]{}),
+	)
+	assert.Nil(cached.retries.deadLetter)
+	assert.Equal([]string{
+		`{"msg":"dead-letter option ignored, built for another record type","type":"*logger.memoryLogger[string]","expected":"logger.Log[int]"}`,
+	}, errLog.Buffer)
+
+	assert.NoError(cached.Log(1))
+	assert.NoError(cached.Close())
+	assert.Equal([]int{1}, inner.records())
+}
+
+func TestRetryQueue_OwnsBatch(t *testing.T) {
+	t.Parallel()
+	assert := require.New(t)
+
+	clock := newFakeClock()
+	inner := &memoryLogger[int]{}
+	q := newRetryQueue[int](inner, nil, nopErrorLog, clock, RetryPolicy{InitialInterval: time.Second}, 1, 1)
+
+	batch := []int{1, 2, 3}
+	q.submit(batch, errors.New("write failed"), nil, nil)
+
+	// The worker reuses its cache while the retry is still waiting
+	batch[0], batch[1], batch[2] = 7, 8, 9
+
+	assert.Eventually(func() bool { return clock.Timers() == 1 }, time.Second, time.Millisecond)
+	clock.Advance(time.Second)
+	q.wait()
+
+	assert.Equal([]int{1, 2, 3}, inner.records())
+	assert.NoError(q.takeErr())
+}
+
+func TestRetryQueue_BoundedConcurrency(t *testing.T) {
+	t.Parallel()
+	assert := require.New(t)
+
+	inner := &memoryLogger[int]{release: make(chan struct{})}
+	q := newRetryQueue[int](inner, nil, nopErrorLog, systemClock{}, RetryPolicy{}, 1, 1)
+
+	q.submit([]int{1}, errors.New("write failed"), nil, nil)
+
+	submitted := make(chan struct{})
+	go func() {
+		q.submit([]int{2}, errors.New("write failed"), nil, nil)
+		close(submitted)
+	}()
+
+	select {
+	case <-submitted:
+		assert.Fail("second batch must wait for a free slot")
+	case <-time.After(20 * time.Millisecond):
+	}
+
+	close(inner.release)
+	<-submitted
+	q.wait()
+
+	assert.ElementsMatch([]int{1, 2}, inner.records())
+}
+
+type retryRecord struct {
+	ID   int
+	Name string
+}
+
+func TestCachedLogging_Retry_MultipleInstantiations(t *testing.T) {
+	t.Parallel()
+
+	t.Run("int", func(t *testing.T) {
+		t.Parallel()
+		testRetryDelivery(t, func(i int) int { return i })
+	})
+
+	t.Run("string", func(t *testing.T) {
+		t.Parallel()
+		testRetryDelivery(t, func(i int) string { return fmt.Sprintf("record-%d", i) })
+	})
+
+	t.Run("struct", func(t *testing.T) {
+		t.Parallel()
+		testRetryDelivery(t, func(i int) retryRecord { return retryRecord{ID: i, Name: "record"} })
+	})
+}
+
+// alternatingLogger rejects every other batch.
+type alternatingLogger[T any] struct {
+	memoryLogger[T]
+	calls atomic.Int32
+}
+
+func (a *alternatingLogger[T]) LogMultiple(data []T) error {
+	if a.calls.Add(1)%2 == 0 {
+		return errors.New("sink is down")
+	}
+
+	return a.memoryLogger.LogMultiple(data)
+}
+
+func testRetryDelivery[T any](t *testing.T, record func(int) T) {
+	assert := require.New(t)
+
+	const records = 1000
+
+	inner := &alternatingLogger[T]{}
+	cached := NewCached[T](context.Background(), inner,
+		WithBufferSize(7),
+		WithWorkerPool(4),
+		WithRetryCount(10),
+		WithRetryPolicy(RetryPolicy{}),
+	)
+
+	expected := make([]T, 0, records)
+	for i := 0; i < records; i++ {
+		expected = append(expected, record(i))
+		assert.NoError(cached.Log(expected[i]))
+	}
+
+	assert.NoError(cached.Close())
+	assert.ElementsMatch(expected, inner.records())
+}
+
+func TestRetryQueue_Panic(t *testing.T) {
+	t.Parallel()
+	assert := require.New(t)
+
+	errLog := error_log.NewMockLogger()
+	q := newRetryQueue[string](&panickingLogger{}, nil, errLog, systemClock{}, RetryPolicy{}, 1, 1)
+
+	var done atomic.Bool
+	q.submit([]string{"boom"}, errors.New("write failed"), func() { done.Store(true) }, nil)
+	q.wait()
+
+	// Reported instead of crashing the process, the slot is free again
+	assert.EqualError(q.takeErr(), "logger: retry panicked: inner logger failed")
+	assert.True(done.Load())
+	assert.Len(errLog.Buffer, 1)
+	assert.Contains(errLog.Buffer[0], `"msg":"retry panicked, batch discarded","records":1,"panic":"inner logger failed"`)
+
+	q.submit([]string{"a"}, errors.New("write failed"), nil, nil)
+	q.wait()
+	assert.NoError(q.takeErr())
+}
@@ -0,0 +1,280 @@
+package logger
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type BackupNaming uint8
+
+const (
+	// BackupNumbered renames the file to path.1, the older backups move up
+	// to path.2, path.3 and so on, like logrotate does.
+	BackupNumbered BackupNaming = iota
+	// BackupTimestamp renames the file to path.<time of the rotation>.
+	BackupTimestamp
+)
+
+// DefaultBackupTimeFormat sorts the timestamped backups by name, oldest
+// first, and is safe in file names.
+const DefaultBackupTimeFormat = "2006-01-02T15-04-05.000"
+
+// SizeRotation rotates the file once the next batch would take it past
+// MaxSize bytes. A batch is never split, so a single batch larger than
+// MaxSize still goes to a file of its own.
+type SizeRotation struct {
+	MaxSize int64
+	// MaxBackups is the number of backups kept, the oldest are removed.
+	// Zero keeps all of them.
+	MaxBackups int
+	Naming     BackupNaming
+	// TimeFormat is the time layout of BackupTimestamp names,
+	// DefaultBackupTimeFormat when empty
+	TimeFormat string
+}
+
+// WithSizeRotation rotates the file by size, see SizeRotation. It keeps the
+// handle open, as WithPersistentHandle does: the rotation happens under the
+// same lock as the writes, so no batch is split between two files.
+func WithSizeRotation(rotation SizeRotation) FileModifier {
+	return func(c *FileConfig) {
+		if rotation.TimeFormat == "" {
+			rotation.TimeFormat = DefaultBackupTimeFormat
+		}
+
+		c.rotation = &rotation
+	}
+}
+
+// WithFileClock replaces the time source used to name the backups and to
+// format the path of WithTimeRotation.
+func WithFileClock(clock Clock) FileModifier {
+	return func(c *FileConfig) {
+		c.clock = clock
+	}
+}
+
+// needsRotation reports whether n more bytes take the file past the size
+// limit; the caller holds h.mu.
+func (h *fileHandle) needsRotation(n int) bool {
+	return h.rotation != nil &&
+		h.rotation.MaxSize > 0 &&
+		h.size > 0 &&
+		h.size+int64(n) > h.rotation.MaxSize
+}
+
+// rotate closes the file and moves it to a backup. When that fails, the
+// file is reopened and written past the limit, no record is lost. The
+// caller holds h.mu.
+func (h *fileHandle) rotate() {
+	// A failed close is reported, the file is rotated all the same
+	_ = h.closeFile()
+
+	var (
+		backup string
+		err    error
+	)
+
+	switch h.rotation.Naming {
+	case BackupTimestamp:
+		backup, err = h.rotateTimestamp()
+	default:
+		backup, err = h.rotateNumbered()
+	}
+
+	if err != nil {
+		if h.error != nil {
+			h.error.Print(failedToRotateFile, h.path, err)
+		}
+		return
+	}
+
+	h.compress(backup)
+	h.rotated()
+}
+
+// rotateNumbered returns the name of the new backup.
+func (h *fileHandle) rotateNumbered() (string, error) {
+	backups := h.backups(func(suffix string) bool {
+		n, err := strconv.Atoi(suffix)
+		return err == nil && n > 0
+	})
+
+	// Highest first, so every rename goes to a free name
+	sort.Slice(backups, func(i, j int) bool {
+		return h.backupNumber(backups[i]) > h.backupNumber(backups[j])
+	})
+
+	for _, backup := range backups {
+		n := h.backupNumber(backup)
+
+		if h.rotation.MaxBackups > 0 && n >= h.rotation.MaxBackups {
+			h.removeBackup(backup)
+			continue
+		}
+
+		// A compressed backup stays compressed
+		if err := os.Rename(backup, h.backupName(strconv.Itoa(n+1))+h.compressed(backup)); err != nil {
+			return "", err
+		}
+	}
+
+	backup := h.backupName("1")
+
+	return backup, os.Rename(h.path, backup)
+}
+
+// rotateTimestamp returns the name of the new backup.
+func (h *fileHandle) rotateTimestamp() (string, error) {
+	format := h.rotation.TimeFormat
+	stamp := h.clock.Now().Format(format)
+	name := h.backupName(stamp)
+
+	// Two rotations within the precision of the format
+	for i := 1; h.backupExists(name); i++ {
+		name = h.backupName(stamp + "-" + strconv.Itoa(i))
+	}
+
+	if err := os.Rename(h.path, name); err != nil {
+		return "", err
+	}
+
+	if h.rotation.MaxBackups <= 0 {
+		return name, nil
+	}
+
+	backups := h.backups(func(suffix string) bool {
+		_, _, ok := parseBackupTime(format, suffix)
+		return ok
+	})
+
+	// Oldest first, the counter orders the ones of the same time
+	sort.Slice(backups, func(i, j int) bool {
+		ti, ni, _ := parseBackupTime(format, h.suffix(backups[i]))
+		tj, nj, _ := parseBackupTime(format, h.suffix(backups[j]))
+
+		if ti.Equal(tj) {
+			return ni < nj
+		}
+
+		return ti.Before(tj)
+	})
+
+	for len(backups) > h.rotation.MaxBackups {
+		h.removeBackup(backups[0])
+		backups = backups[1:]
+	}
+
+	return name, nil
+}
+
+// backups lists the files next to the active one whose name is the path
+// followed by a dot and a suffix that matches, compressed or not.
+func (h *fileHandle) backups(match func(suffix string) bool) []string {
+	dir, base := filepath.Split(h.path)
+	if dir == "" {
+		dir = "."
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil
+	}
+
+	prefix := base + "."
+	backups := make([]string, 0, len(entries))
+
+	for _, entry := range entries {
+		name := entry.Name()
+
+		if entry.IsDir() || !strings.HasPrefix(name, prefix) || !match(h.trimCompressed(name[len(prefix):])) {
+			continue
+		}
+
+		backups = append(backups, filepath.Join(dir, name))
+	}
+
+	return backups
+}
+
+func (h *fileHandle) backupName(suffix string) string {
+	return fmt.Sprintf("%s.%s", h.path, suffix)
+}
+
+// suffix returns what follows the name of the active file in a backup
+// name, without the extension of the compression.
+func (h *fileHandle) suffix(backup string) string {
+	return h.trimCompressed(strings.TrimPrefix(filepath.Base(backup), filepath.Base(h.path)+"."))
+}
+
+// compressed returns the extension of the compression when the backup has
+// it, an empty string otherwise.
+func (h *fileHandle) compressed(backup string) string {
+	if h.compression == nil {
+		return ""
+	}
+
+	if ext := h.compression.compressor.Extension(); strings.HasSuffix(backup, ext) {
+		return ext
+	}
+
+	return ""
+}
+
+func (h *fileHandle) trimCompressed(name string) string {
+	return strings.TrimSuffix(name, h.compressed(name))
+}
+
+func (h *fileHandle) backupExists(backup string) bool {
+	if h.compression != nil && fileExists(backup+h.compression.compressor.Extension()) {
+		return true
+	}
+
+	return fileExists(backup)
+}
+
+func (h *fileHandle) removeBackup(path string) {
+	if err := os.Remove(path); err != nil && !os.IsNotExist(err) && h.error != nil {
+		h.error.Print(failedToRemoveTheFile, path, err)
+	}
+}
+
+func (h *fileHandle) backupNumber(backup string) int {
+	n, _ := strconv.Atoi(h.suffix(backup))
+	return n
+}
+
+// parseBackupTime reads the time of a timestamped backup suffix and the
+// counter added on a collision.
+func parseBackupTime(format, suffix string) (time.Time, int, bool) {
+	if t, err := time.Parse(format, suffix); err == nil {
+		return t, 0, true
+	}
+
+	i := strings.LastIndexByte(suffix, '-')
+	if i <= 0 {
+		return time.Time{}, 0, false
+	}
+
+	n, err := strconv.Atoi(suffix[i+1:])
+	if err != nil {
+		return time.Time{}, 0, false
+	}
+
+	t, err := time.Parse(format, suffix[:i])
+	if err != nil {
+		return time.Time{}, 0, false
+	}
+
+	return t, n, true
+}
+
+func fileExists(path string) bool {
+	_, err := os.Lstat(path)
+	return err == nil
+}
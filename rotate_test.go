@@ -0,0 +1,139 @@
+package logger
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	realSerializer "github.com/nano-interactive/go-logger/serializer"
+)
+
+func readLines(t *testing.T, path string) []string {
+	t.Helper()
+
+	content, err := os.ReadFile(path)
+	require.NoError(t, err)
+
+	return strings.Split(strings.TrimSuffix(string(content), "\n"), "\n")
+}
+
+func TestFileLogger_SizeRotation_Numbered(t *testing.T) {
+	t.Parallel()
+	assert := require.New(t)
+
+	path := filepath.Join(t.TempDir(), "test.json")
+
+	// Every record is 13 bytes, two fit in a file
+	fileLogger := NewFileLoggerWithOptions[logData](path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644, realSerializer.NewJson[logData](),
+		WithSizeRotation(SizeRotation{MaxSize: 30, MaxBackups: 2}),
+	)
+
+	for _, name := range []string{"a", "b", "c", "d", "e", "f", "g"} {
+		assert.NoError(fileLogger.Log(logData{Name: name}))
+	}
+
+	assert.NoError(fileLogger.Close())
+
+	assert.Equal([]string{`{"name":"g"}`}, readLines(t, path))
+	assert.Equal([]string{`{"name":"e"}`, `{"name":"f"}`}, readLines(t, path+".1"))
+	assert.Equal([]string{`{"name":"c"}`, `{"name":"d"}`}, readLines(t, path+".2"))
+	assert.NoFileExists(path + ".3")
+}
+
+func TestFileLogger_SizeRotation_Timestamp(t *testing.T) {
+	t.Parallel()
+	assert := require.New(t)
+
+	dir := t.TempDir()
+	path := filepath.Join(dir, "test.json")
+	clock := newFakeClock()
+
+	fileLogger := NewFileLoggerWithOptions[logData](path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644, realSerializer.NewJson[logData](),
+		WithSizeRotation(SizeRotation{MaxSize: 20, MaxBackups: 3, Naming: BackupTimestamp, TimeFormat: "20060102-150405"}),
+		WithFileClock(clock),
+	)
+
+	start := clock.Now()
+
+	// One record per file, the first two rotations happen within the
+	// same second
+	for i, name := range []string{"a", "b", "c", "d", "e"} {
+		if i != 2 {
+			clock.Advance(time.Second)
+		}
+
+		assert.NoError(fileLogger.Log(logData{Name: name}))
+	}
+
+	assert.NoError(fileLogger.Close())
+
+	entries, err := os.ReadDir(dir)
+	assert.NoError(err)
+
+	names := make([]string, 0, len(entries))
+	for _, entry := range entries {
+		names = append(names, entry.Name())
+	}
+
+	sort.Strings(names)
+
+	stamp := func(d time.Duration) string {
+		return "test.json." + start.Add(d).Format("20060102-150405")
+	}
+
+	// The oldest backup, holding a, is gone
+	assert.Equal([]string{"test.json", stamp(2*time.Second) + "-1", stamp(3 * time.Second), stamp(4 * time.Second)}, names)
+	assert.Equal([]string{`{"name":"b"}`}, readLines(t, filepath.Join(dir, stamp(2*time.Second)+"-1")))
+	assert.Equal([]string{`{"name":"d"}`}, readLines(t, filepath.Join(dir, stamp(4*time.Second))))
+	assert.Equal([]string{`{"name":"e"}`}, readLines(t, path))
+}
+
+func TestFileLogger_SizeRotation_Concurrent(t *testing.T) {
+	t.Parallel()
+	assert := require.New(t)
+
+	dir := t.TempDir()
+	path := filepath.Join(dir, "test.json")
+
+	fileLogger := NewFileLoggerWithOptions[logData](path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644, realSerializer.NewJson[logData](),
+		WithSizeRotation(SizeRotation{MaxSize: 100}),
+	)
+
+	const writers, batches = 8, 50
+
+	var wg sync.WaitGroup
+	wg.Add(writers)
+
+	for i := 0; i < writers; i++ {
+		go func() {
+			defer wg.Done()
+
+			for j := 0; j < batches; j++ {
+				assert.NoError(fileLogger.LogMultiple([]logData{{Name: "a"}, {Name: "b"}, {Name: "c"}}))
+			}
+		}()
+	}
+
+	wg.Wait()
+	assert.NoError(fileLogger.Close())
+
+	files, err := filepath.Glob(path + "*")
+	assert.NoError(err)
+
+	lines := 0
+	for _, file := range files {
+		content := readLines(t, file)
+
+		// A batch never straddles two files
+		assert.Zero(len(content)%3, file)
+		lines += len(content)
+	}
+
+	assert.Equal(writers*batches*3, lines)
+}
@@ -3,6 +3,8 @@ package serializer
 import (
 	"bytes"
 	"encoding/json"
+	"errors"
+	"io"
 	"sync"
 )
 
@@ -20,6 +22,7 @@ type (
 
 var (
 	_ Interface[any]        = &Json[any]{}
+	_ Codec[any]            = &Json[any]{}
 	_ PooledSerializer[any] = &PoolJsonSerializer[any]{}
 )
 
@@ -42,6 +45,27 @@ func (j *Json[T]) Serialize(data []T) ([]byte, error) {
 	return buf.Bytes(), nil
 }
 
+// Deserialize reads back the newline delimited JSON written by Serialize.
+func (j *Json[T]) Deserialize(data []byte) ([]T, error) {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	result := make([]T, 0, bytes.Count(data, []byte{'\n'}))
+
+	for {
+		var v T
+
+		err := dec.Decode(&v)
+		if errors.Is(err, io.EOF) {
+			return result, nil
+		}
+
+		if err != nil {
+			return nil, err
+		}
+
+		result = append(result, v)
+	}
+}
+
 func NewPoolJson[T any](buff *bytes.Buffer) *PoolJsonSerializer[T] {
 	return &PoolJsonSerializer[T]{
 		buf: buff,
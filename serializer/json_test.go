@@ -1 +1,33 @@
 package serializer
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+type record struct {
+	Name string `json:"name"`
+}
+
+func TestJson_Deserialize(t *testing.T) {
+	t.Parallel()
+	assert := require.New(t)
+
+	s := NewJson[record]()
+	data := []record{{Name: "test 1"}, {Name: "test 2"}}
+
+	raw, err := s.Serialize(data)
+	assert.NoError(err)
+
+	result, err := s.Deserialize(raw)
+	assert.NoError(err)
+	assert.Equal(data, result)
+
+	result, err = s.Deserialize(nil)
+	assert.NoError(err)
+	assert.Empty(result)
+
+	_, err = s.Deserialize([]byte("{\"name\":"))
+	assert.Error(err)
+}
@@ -8,6 +8,17 @@ type (
 	Interface[T any] interface {
 		Serialize([]T) ([]byte, error)
 	}
+	Deserializer[T any] interface {
+		Deserialize([]byte) ([]T, error)
+	}
+
+	// Codec serializes records and reads them back, as needed by anything
+	// that persists records and replays them later.
+	Codec[T any] interface {
+		Interface[T]
+		Deserializer[T]
+	}
+
 	PooledSerializer[T any] interface {
 		Interface[T]
 		getBuffer() *bytes.Buffer
@@ -0,0 +1,135 @@
+package logger
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+type keyedRecord struct {
+	Key uint64
+	Seq int
+}
+
+func TestShard_Spread(t *testing.T) {
+	t.Parallel()
+	assert := require.New(t)
+
+	counts := make([]int, 8)
+	for key := uint64(0); key < 8000; key++ {
+		counts[shard(key, 8)]++
+	}
+
+	for _, count := range counts {
+		assert.InDelta(1000, count, 150)
+	}
+}
+
+func TestCachedLogging_ShardKey_PreservesOrder(t *testing.T) {
+	t.Parallel()
+
+	t.Run("healthy sink", func(t *testing.T) {
+		t.Parallel()
+		testShardOrder(t, &memoryLogger[keyedRecord]{}, func(l Log[keyedRecord]) []keyedRecord {
+			return l.(*memoryLogger[keyedRecord]).records()
+		})
+	})
+
+	t.Run("retried batches", func(t *testing.T) {
+		t.Parallel()
+		testShardOrder(t, &alternatingLogger[keyedRecord]{}, func(l Log[keyedRecord]) []keyedRecord {
+			return l.(*alternatingLogger[keyedRecord]).records()
+		})
+	})
+}
+
+func testShardOrder(t *testing.T, inner Log[keyedRecord], records func(Log[keyedRecord]) []keyedRecord) {
+	assert := require.New(t)
+
+	const (
+		keys    = 16
+		perKey  = 200
+		workers = 4
+	)
+
+	cached := NewCached[keyedRecord](context.Background(), inner,
+		WithBufferSize(7),
+		WithWorkerPool(workers),
+		WithRetryCount(10),
+		WithRetryPolicy(RetryPolicy{}),
+		WithShardKey(func(r keyedRecord) uint64 { return r.Key }),
+	)
+
+	var wg sync.WaitGroup
+
+	for key := uint64(0); key < keys; key++ {
+		wg.Add(1)
+		go func(key uint64) {
+			defer wg.Done()
+
+			for seq := 0; seq < perKey; seq += 2 {
+				if seq%4 == 0 {
+					assert.NoError(cached.Log(keyedRecord{Key: key, Seq: seq}))
+					assert.NoError(cached.Log(keyedRecord{Key: key, Seq: seq + 1}))
+					continue
+				}
+
+				assert.NoError(cached.LogMultiple([]keyedRecord{{Key: key, Seq: seq}, {Key: key, Seq: seq + 1}}))
+			}
+		}(key)
+	}
+
+	wg.Wait()
+	assert.NoError(cached.Close())
+
+	next := make(map[uint64]int, keys)
+	written := records(inner)
+
+	assert.Len(written, keys*perKey)
+
+	for _, r := range written {
+		assert.Equal(next[r.Key], r.Seq, "key %d out of order", r.Key)
+		next[r.Key]++
+	}
+}
+
+func TestCachedLogging_ShardKey_RebalanceHungSink(t *testing.T) {
+	t.Parallel()
+	assert := require.New(t)
+
+	inner := &memoryLogger[int]{release: make(chan struct{})}
+	cached := NewCached[int](context.Background(), inner,
+		WithBufferSize(1),
+		WithShardKey[int](func(i int) uint64 { return uint64(i) }),
+	)
+
+	// The worker hangs in the sink with the record
+	assert.NoError(cached.Log(1))
+
+	resized := make(chan error, 1)
+	go func() {
+		resized <- cached.Reconfigure(WithWorkerPool(2))
+	}()
+
+	assert.Eventually(func() bool {
+		return cached.lanes[0].blocked.Load() != nil
+	}, time.Second, time.Millisecond)
+
+	// The producers of the lane wait for the rebalance as long as their
+	// context allows, the rest of the logger is not held up
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	assert.ErrorIs(cached.LogContext(ctx, 2), context.DeadlineExceeded)
+	assert.Len(cached.Stats().Workers, 1)
+
+	close(inner.release)
+	assert.NoError(<-resized)
+	assert.Len(cached.Stats().Workers, 2)
+
+	assert.NoError(cached.Log(3))
+	assert.NoError(cached.Close())
+	assert.Equal([]int{1, 3}, inner.records())
+}
@@ -0,0 +1,430 @@
+package logger
+
+import (
+	"context"
+	"encoding/binary"
+	"fmt"
+	"hash/crc32"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"sync/atomic"
+
+	"github.com/nano-interactive/go-logger/serializer"
+)
+
+const (
+	spoolExt                = ".spool"
+	spoolFrameHeader        = 8
+	defaultSpoolSegmentSize = 64 << 20
+)
+
+var spoolCRC = crc32.MakeTable(crc32.Castagnoli)
+
+type spoolSegment struct {
+	path string
+	// end is the sequence number following the last record in the segment
+	end  uint64
+	size int64
+}
+
+// spool is the write-ahead log of one worker. Every record gets a sequence
+// number in the order the worker receives it; the worker acknowledges
+// delivered ranges and a segment is removed once all its records are
+// acknowledged. Frames are [len uint32][crc32 uint32][payload].
+type spool struct {
+	// mu is held by the producer across the channel send and the append,
+	// so the segments keep the order the worker sees
+	mu          sync.Mutex
+	dir         string
+	name        string
+	segmentSize int64
+	error       Error
+	file        *os.File
+	path        string
+	size        int64
+	counter     int
+	next        uint64
+	frame       []byte
+	// disk is the size of the segments not removed yet, the active one
+	// included
+	disk atomic.Int64
+
+	ackMu     sync.Mutex
+	sealed    []spoolSegment
+	watermark uint64
+	pending   map[uint64]uint64
+	// closed stops the truncation, late acks after a timed out Shutdown
+	// can not be matched to the records anymore
+	closed bool
+	// waiting is closed once the watermark reaches waitEnd, see delivered
+	waiting chan struct{}
+	waitEnd uint64
+}
+
+func newSpool(dir, name string, segmentSize int64, errorLog Error) *spool {
+	return &spool{
+		dir:         dir,
+		name:        name,
+		segmentSize: segmentSize,
+		error:       errorLog,
+		pending:     make(map[uint64]uint64),
+	}
+}
+
+// append writes the serialized records; the caller holds s.mu. A failed
+// write still consumes the sequence numbers, the records are queued anyway.
+func (s *spool) append(payload []byte, count int) {
+	s.next += uint64(count)
+
+	if s.file == nil {
+		s.path = filepath.Join(s.dir, fmt.Sprintf("%s-%06d%s", s.name, s.counter, spoolExt))
+		s.counter++
+
+		file, err := os.OpenFile(s.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o600)
+		if err != nil {
+			s.error.Print(failedToOpenFile, s.path, err)
+			return
+		}
+
+		s.file = file
+		s.size = 0
+	}
+
+	s.frame = append(s.frame[:0], make([]byte, spoolFrameHeader)...)
+	binary.LittleEndian.PutUint32(s.frame[0:4], uint32(len(payload)))
+	binary.LittleEndian.PutUint32(s.frame[4:8], crc32.Checksum(payload, spoolCRC))
+	s.frame = append(s.frame, payload...)
+
+	n, err := s.file.Write(s.frame)
+	s.size += int64(n)
+	s.disk.Add(int64(n))
+
+	if err != nil {
+		s.error.Print(failedToWriteToTheFile, s.path, err)
+	}
+
+	if s.size >= s.segmentSize {
+		s.seal()
+	}
+}
+
+// skip consumes the sequence numbers of records that were queued but could
+// not be written to the spool; the caller holds s.mu.
+func (s *spool) skip(count int) {
+	s.next += uint64(count)
+}
+
+// seal closes the active segment; the next append starts a new one.
+func (s *spool) seal() {
+	if s.file == nil {
+		return
+	}
+
+	if err := s.file.Close(); err != nil {
+		s.error.Print(failedToCloseTheFile, s.path, err)
+	}
+
+	s.file = nil
+
+	s.ackMu.Lock()
+	s.sealed = append(s.sealed, spoolSegment{path: s.path, end: s.next, size: s.size})
+	s.truncate()
+	s.ackMu.Unlock()
+}
+
+// ack marks the records in [start, end) as delivered.
+func (s *spool) ack(start, end uint64) {
+	if start == end {
+		return
+	}
+
+	s.ackMu.Lock()
+	defer s.ackMu.Unlock()
+
+	if s.closed {
+		return
+	}
+
+	// Retried batches finish out of order, only a contiguous range moves
+	// the watermark
+	if start != s.watermark {
+		s.pending[start] = end
+		return
+	}
+
+	s.watermark = end
+
+	for {
+		next, ok := s.pending[s.watermark]
+		if !ok {
+			break
+		}
+
+		delete(s.pending, s.watermark)
+		s.watermark = next
+	}
+
+	if s.waiting != nil && s.watermark >= s.waitEnd {
+		close(s.waiting)
+		s.waiting = nil
+	}
+
+	s.truncate()
+}
+
+// delivered returns a channel closed once every record appended so far is
+// acknowledged. Only one caller may wait at a time.
+func (s *spool) delivered() <-chan struct{} {
+	s.mu.Lock()
+	end := s.next
+	s.mu.Unlock()
+
+	s.ackMu.Lock()
+	defer s.ackMu.Unlock()
+
+	ch := make(chan struct{})
+
+	if s.watermark >= end {
+		close(ch)
+		return ch
+	}
+
+	s.waiting, s.waitEnd = ch, end
+
+	return ch
+}
+
+// truncate removes the sealed segments that were fully delivered; the
+// caller holds s.ackMu.
+func (s *spool) truncate() {
+	removed := 0
+
+	for _, segment := range s.sealed {
+		if segment.end > s.watermark {
+			break
+		}
+
+		if err := os.Remove(segment.path); err != nil && !os.IsNotExist(err) {
+			s.error.Print(failedToRemoveTheFile, segment.path, err)
+		}
+
+		s.disk.Add(-segment.size)
+		removed++
+	}
+
+	s.sealed = s.sealed[removed:]
+}
+
+func (s *spool) close() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.seal()
+
+	s.ackMu.Lock()
+	s.closed = true
+	s.ackMu.Unlock()
+}
+
+// openSpools starts replaying the segments of the previous run and returns
+// the function creating the spool of a worker. It returns nil when no spool
+// is configured or the directory can not be used.
+func openSpools[T any](config *CachedLoggingConfig, codec serializer.Codec[T], log Log[T], limit batchLimit[T], wg *sync.WaitGroup) func(worker int) *spool {
+	if config.spoolDir == "" || codec == nil {
+		return nil
+	}
+
+	if err := os.MkdirAll(config.spoolDir, 0o750); err != nil {
+		config.logger.Print(failedToCreateSpool, config.spoolDir, err)
+		return nil
+	}
+
+	// New segments are created lazily, so this only sees the old ones
+	files, err := spoolSegments(config.spoolDir)
+	if err != nil {
+		config.logger.Print(failedToCreateSpool, config.spoolDir, err)
+		return nil
+	}
+
+	if len(files) > 0 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			replaySpool(files, codec, log, limit, config.logger)
+		}()
+	}
+
+	generation := config.clock.Now().UnixNano()
+	dir, size, errorLog := config.spoolDir, config.spoolSize, config.logger
+
+	return func(worker int) *spool {
+		return newSpool(dir, fmt.Sprintf("%d-%03d", generation, worker), size, errorLog)
+	}
+}
+
+// enqueueSpooled queues the record and, with a spool, appends it before
+// returning. The record is serialized up front so the lock only covers the
+// send and the write.
+func (l *CachedLogging[T]) enqueueSpooled(ctx context.Context, ln *lane[T], w *logWorker[T], log T) error {
+	if w.spool == nil {
+		err := l.enqueue(ctx, ln, w, log)
+		if err == nil {
+			w.stats.enqueued.Add(1)
+		}
+
+		return err
+	}
+
+	payload, err := l.codec.Serialize([]T{log})
+	if err != nil {
+		l.error.Print(failedToSerializeTheData, err)
+		return err
+	}
+
+	s := w.spool
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err = l.enqueue(ctx, ln, w, log); err != nil {
+		return err
+	}
+
+	s.append(payload, 1)
+	w.stats.enqueued.Add(1)
+
+	return nil
+}
+
+// enqueueBatchSpooled queues the records on one worker and, with a spool,
+// appends them as a single frame before returning.
+func (l *CachedLogging[T]) enqueueBatchSpooled(ctx context.Context, ln *lane[T], w *logWorker[T], records []T) error {
+	if w.spool == nil {
+		queued, err := l.enqueueBatch(ctx, ln, w, records)
+		w.stats.enqueued.Add(uint64(len(queued)))
+
+		return err
+	}
+
+	payload, err := l.codec.Serialize(records)
+	if err != nil {
+		l.error.Print(failedToSerializeTheData, err)
+		return err
+	}
+
+	s := w.spool
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	queued, err := l.enqueueBatch(ctx, ln, w, records)
+	if len(queued) == 0 {
+		return err
+	}
+
+	// Some records were dropped, the frame holds only the queued ones
+	if len(queued) < len(records) {
+		var serializeErr error
+
+		if payload, serializeErr = l.codec.Serialize(queued); serializeErr != nil {
+			l.error.Print(failedToSerializeTheData, serializeErr)
+			s.skip(len(queued))
+			w.stats.enqueued.Add(uint64(len(queued)))
+
+			return err
+		}
+	}
+
+	s.append(payload, len(queued))
+	w.stats.enqueued.Add(uint64(len(queued)))
+
+	return err
+}
+
+// spoolSegments lists the segments left behind by previous runs.
+func spoolSegments(dir string) ([]string, error) {
+	files, err := filepath.Glob(filepath.Join(dir, "*"+spoolExt))
+	if err != nil {
+		return nil, err
+	}
+
+	sort.Strings(files)
+
+	return files, nil
+}
+
+// replaySpool writes the records of old segments to the inner logger and
+// removes every segment that was delivered. Undelivered segments are kept
+// for the next start.
+func replaySpool[T any](files []string, codec serializer.Codec[T], log Log[T], limit batchLimit[T], errorLog Error) {
+	for _, path := range files {
+		records, err := readSpoolSegment(path, codec, errorLog)
+		if err != nil {
+			errorLog.Print(failedToReplaySpool, path, err)
+			continue
+		}
+
+		delivered := true
+
+		for _, batch := range limit.split(records) {
+			if err = log.LogMultiple(batch); err != nil {
+				errorLog.Print(failedToReplaySpool, path, err)
+				delivered = false
+				break
+			}
+		}
+
+		if !delivered {
+			continue
+		}
+
+		if err = os.Remove(path); err != nil {
+			errorLog.Print(failedToRemoveTheFile, path, err)
+		}
+	}
+}
+
+func readSpoolSegment[T any](path string, codec serializer.Codec[T], errorLog Error) ([]T, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	records := make([]T, 0)
+
+	for len(data) > 0 {
+		if len(data) < spoolFrameHeader {
+			errorLog.Print(spoolFrameCorrupted, path, len(data))
+			break
+		}
+
+		length := int(binary.LittleEndian.Uint32(data[0:4]))
+		sum := binary.LittleEndian.Uint32(data[4:8])
+
+		// A crash in the middle of a write leaves a torn last frame
+		if len(data)-spoolFrameHeader < length {
+			errorLog.Print(spoolFrameCorrupted, path, len(data))
+			break
+		}
+
+		payload := data[spoolFrameHeader : spoolFrameHeader+length]
+		data = data[spoolFrameHeader+length:]
+
+		if crc32.Checksum(payload, spoolCRC) != sum {
+			errorLog.Print(spoolFrameCorrupted, path, length)
+			continue
+		}
+
+		decoded, err := codec.Deserialize(payload)
+		if err != nil {
+			errorLog.Print(failedToSerializeTheData, err)
+			continue
+		}
+
+		records = append(records, decoded...)
+	}
+
+	return records, nil
+}
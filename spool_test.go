@@ -0,0 +1,192 @@
+package logger
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/nano-interactive/go-logger/__mocks__/error_log"
+	realSerializer "github.com/nano-interactive/go-logger/serializer"
+)
+
+func spoolFiles(t *testing.T, dir string) []string {
+	files, err := spoolSegments(dir)
+	require.NoError(t, err)
+
+	return files
+}
+
+func TestSpool_TruncatesDeliveredSegments(t *testing.T) {
+	t.Parallel()
+	assert := require.New(t)
+
+	dir := t.TempDir()
+	inner := &memoryLogger[logData]{release: make(chan struct{})}
+
+	cached := NewCached[logData](context.Background(), inner,
+		WithBufferSize(2),
+		WithSpool[logData](dir, realSerializer.NewJson[logData]()),
+		// Every record seals its own segment
+		WithSpoolSegmentSize(1),
+	)
+
+	// A batch is a single frame, separate records get a segment each
+	assert.NoError(cached.Log(logData{Name: "a"}))
+	assert.NoError(cached.Log(logData{Name: "b"}))
+	assert.NoError(cached.Log(logData{Name: "c"}))
+
+	// The sink is blocked, everything is still on disk
+	assert.Len(spoolFiles(t, dir), 3)
+
+	close(inner.release)
+	assert.NoError(cached.Flush(context.Background()))
+	assert.Empty(spoolFiles(t, dir))
+
+	assert.NoError(cached.Log(logData{Name: "d"}))
+	assert.NoError(cached.Close())
+
+	assert.Empty(spoolFiles(t, dir))
+	assert.Equal([]logData{{Name: "a"}, {Name: "b"}, {Name: "c"}, {Name: "d"}}, inner.records())
+}
+
+func TestSpool_RetiredWorker(t *testing.T) {
+	t.Parallel()
+	assert := require.New(t)
+
+	dir := t.TempDir()
+	inner := &memoryLogger[logData]{}
+
+	cached := NewCached[logData](context.Background(), inner,
+		WithBufferSize(1),
+		WithWorkerPool(4),
+		WithSpool[logData](dir, realSerializer.NewJson[logData]()),
+	)
+
+	// One record per worker, each opens a segment
+	for _, name := range []string{"a", "b", "c", "d"} {
+		assert.NoError(cached.Log(logData{Name: name}))
+	}
+
+	assert.NoError(cached.Flush(context.Background()))
+	assert.Len(spoolFiles(t, dir), 4)
+
+	retired := cached.lanes[0].workers[1:]
+	assert.NoError(cached.Reconfigure(WithWorkerPool(1)))
+
+	// The spools of the retired workers are closed and their delivered
+	// segments removed
+	assert.Len(cached.spools, 1)
+	assert.Len(spoolFiles(t, dir), 1)
+
+	for _, w := range retired {
+		assert.Nil(w.spool.file)
+	}
+
+	assert.NoError(cached.Close())
+	assert.Empty(spoolFiles(t, dir))
+	assert.Len(inner.records(), 4)
+}
+
+func TestSpool_KeepsSegmentUntilRetrySucceeds(t *testing.T) {
+	t.Parallel()
+	assert := require.New(t)
+
+	dir := t.TempDir()
+	clock := newFakeClock()
+	inner := &flakyLogger[logData]{failures: 1}
+
+	cached := NewCached[logData](context.Background(), inner,
+		WithBufferSize(1),
+		WithClock(clock),
+		WithRetryPolicy(RetryPolicy{InitialInterval: time.Second}),
+		WithSpool[logData](dir, realSerializer.NewJson[logData]()),
+		WithSpoolSegmentSize(1),
+	)
+
+	assert.NoError(cached.Log(logData{Name: "a"}))
+	assert.Eventually(func() bool { return clock.Timers() == 1 }, time.Second, time.Millisecond)
+	assert.Len(spoolFiles(t, dir), 1)
+
+	clock.Advance(time.Second)
+	assert.NoError(cached.Flush(context.Background()))
+	assert.Empty(spoolFiles(t, dir))
+	assert.NoError(cached.Close())
+}
+
+func TestSpool_ReplayOnStart(t *testing.T) {
+	t.Parallel()
+	assert := require.New(t)
+
+	dir := t.TempDir()
+	codec := realSerializer.NewJson[logData]()
+	errLog := error_log.NewMockLogger()
+
+	// Segment left behind by a crashed process, with a torn last frame
+	s := newSpool(dir, "0-000", defaultSpoolSegmentSize, errLog)
+	for _, name := range []string{"a", "b", "c"} {
+		payload, err := codec.Serialize([]logData{{Name: name}})
+		assert.NoError(err)
+		s.append(payload, 1)
+	}
+	_, err := s.file.Write([]byte{0xff, 0x00, 0x00})
+	assert.NoError(err)
+	assert.NoError(s.file.Close())
+
+	inner := &memoryLogger[logData]{}
+	cached := NewCached[logData](context.Background(), inner,
+		WithCachedErrorLogger(errLog),
+		WithSpool[logData](dir, codec),
+	)
+	assert.NoError(cached.Close())
+
+	assert.Equal([]logData{{Name: "a"}, {Name: "b"}, {Name: "c"}}, inner.records())
+	assert.Equal([]string{`{"msg":"corrupted frame in the spool segment ` + s.path + `","bytes":3}`}, errLog.Buffer)
+	assert.Empty(spoolFiles(t, dir))
+}
+
+func TestSpool_ReplayFailureKeepsSegment(t *testing.T) {
+	t.Parallel()
+	assert := require.New(t)
+
+	dir := t.TempDir()
+	codec := realSerializer.NewJson[logData]()
+
+	payload, err := codec.Serialize([]logData{{Name: "a"}})
+	assert.NoError(err)
+
+	s := newSpool(dir, "0-000", defaultSpoolSegmentSize, nopErrorLog)
+	s.append(payload, 1)
+	s.close()
+
+	inner := &memoryLogger[logData]{err: errors.New("sink is down")}
+	cached := NewCached[logData](context.Background(), inner, WithSpool[logData](dir, codec))
+	assert.NoError(cached.Close())
+
+	assert.Equal([]string{filepath.Join(dir, "0-000-000000.spool")}, spoolFiles(t, dir))
+}
+
+func TestSpool_InvalidDirectory(t *testing.T) {
+	t.Parallel()
+	assert := require.New(t)
+
+	file := filepath.Join(t.TempDir(), "file")
+	assert.NoError(os.WriteFile(file, nil, 0o600))
+
+	errLog := error_log.NewMockLogger()
+	inner := &memoryLogger[logData]{}
+	cached := NewCached[logData](context.Background(), inner,
+		WithCachedErrorLogger(errLog),
+		WithSpool[logData](file, realSerializer.NewJson[logData]()),
+	)
+
+	// The logger keeps working without the spool
+	assert.NoError(cached.Log(logData{Name: "a"}))
+	assert.NoError(cached.Close())
+	assert.Len(errLog.Buffer, 1)
+	assert.Equal([]logData{{Name: "a"}}, inner.records())
+}
@@ -0,0 +1,205 @@
+package logger
+
+import (
+	"expvar"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// WorkerStats is a snapshot of the counters of one worker since NewCached.
+type WorkerStats struct {
+	Worker     int      `json:"worker"`
+	Priority   Priority `json:"priority"`
+	QueueDepth int      `json:"queue_depth"`
+	// Enqueued is the number of records accepted into the worker channel
+	Enqueued uint64 `json:"enqueued"`
+	// Records is the number of records the inner logger accepted
+	Records uint64 `json:"records"`
+	// Batches is the number of batches the inner logger accepted, retries
+	// included
+	Batches uint64 `json:"batches"`
+	// Bytes is only known for the loggers of this package, it stays zero
+	// for any other inner logger
+	Bytes        uint64    `json:"bytes"`
+	FailedWrites uint64    `json:"failed_writes"`
+	Retries      uint64    `json:"retries"`
+	Dropped      uint64    `json:"dropped"`
+	Restarts     uint64    `json:"restarts"`
+	LastError    time.Time `json:"last_error"`
+	// Held is the number of records a paused worker holds
+	Held int `json:"held"`
+}
+
+// Stats is a snapshot of every worker of a CachedLogging.
+type Stats struct {
+	// Paused is set between Pause and Resume
+	Paused  bool          `json:"paused"`
+	Workers []WorkerStats `json:"workers"`
+	// Draining are the workers removed by autoscaling that still write
+	// their queue
+	Draining []WorkerStats `json:"draining,omitempty"`
+	// Retired sums the counters of the workers that are gone
+	Retired WorkerStats `json:"retired"`
+}
+
+// Total sums the counters of all the workers, retired ones included.
+// LastError is the most recent one.
+func (s Stats) Total() WorkerStats {
+	total := WorkerStats{Worker: -1}
+
+	total.add(s.Retired)
+
+	for _, w := range s.Workers {
+		total.add(w)
+	}
+
+	for _, w := range s.Draining {
+		total.add(w)
+	}
+
+	return total
+}
+
+func (s *WorkerStats) add(w WorkerStats) {
+	s.QueueDepth += w.QueueDepth
+	s.Enqueued += w.Enqueued
+	s.Records += w.Records
+	s.Batches += w.Batches
+	s.Bytes += w.Bytes
+	s.FailedWrites += w.FailedWrites
+	s.Retries += w.Retries
+	s.Dropped += w.Dropped
+	s.Restarts += w.Restarts
+	s.Held += w.Held
+
+	if w.LastError.After(s.LastError) {
+		s.LastError = w.LastError
+	}
+}
+
+// workerStats is updated by the producers, the worker and the retry queue.
+type workerStats struct {
+	enqueued  atomic.Uint64
+	records   atomic.Uint64
+	batches   atomic.Uint64
+	bytes     atomic.Uint64
+	failed    atomic.Uint64
+	retries   atomic.Uint64
+	dropped   atomic.Uint64
+	lastError atomic.Int64
+	held      atomic.Int64
+	// buffered counts the records taken off the queue and not written or
+	// handed to the retry queue yet, for Shutdown
+	buffered atomic.Int64
+	// The first write of every batch, for the autoscaler
+	flushes    atomic.Uint64
+	flushNanos atomic.Uint64
+}
+
+// written records the outcome of one LogMultiple call. A nil receiver is
+// allowed, for batches that belong to no worker.
+func (s *workerStats) written(records, n int, err error, clock Clock) {
+	if s == nil {
+		return
+	}
+
+	if err != nil {
+		s.failed.Add(1)
+		s.lastError.Store(clock.Now().UnixNano())
+		return
+	}
+
+	s.records.Add(uint64(records))
+	s.batches.Add(1)
+	s.bytes.Add(uint64(n))
+}
+
+// writeBatch hands the batch to the inner logger, counting the bytes when
+// the logger can tell.
+func writeBatch[T any](log Log[T], batch []T) (int, error) {
+	if sized, ok := log.(sizedLog[T]); ok {
+		return sized.logMultipleSized(batch)
+	}
+
+	return 0, log.LogMultiple(batch)
+}
+
+// Stats returns the counters of every worker, in lane and worker order.
+func (l *CachedLogging[T]) Stats() Stats {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+
+	stats := Stats{Paused: l.paused.Load(), Retired: l.retired}
+
+	for _, ln := range l.lanes {
+		for _, w := range ln.workers {
+			stats.Workers = append(stats.Workers, w.snapshot())
+		}
+	}
+
+	for _, w := range l.draining {
+		stats.Draining = append(stats.Draining, w.snapshot())
+	}
+
+	return stats
+}
+
+func (w *logWorker[T]) snapshot() WorkerStats {
+	var lastError time.Time
+	if nanos := w.stats.lastError.Load(); nanos != 0 {
+		lastError = time.Unix(0, nanos)
+	}
+
+	return WorkerStats{
+		Worker:       w.id,
+		Priority:     w.priority,
+		QueueDepth:   w.queue.len(),
+		Enqueued:     w.stats.enqueued.Load(),
+		Records:      w.stats.records.Load(),
+		Batches:      w.stats.batches.Load(),
+		Bytes:        w.stats.bytes.Load(),
+		FailedWrites: w.stats.failed.Load(),
+		Retries:      w.stats.retries.Load(),
+		Dropped:      w.stats.dropped.Load(),
+		Restarts:     w.restarts.Load(),
+		LastError:    lastError,
+		Held:         int(w.stats.held.Load()),
+	}
+}
+
+// Metrics sums the counters of every worker, see MetricsHandler. The flush
+// latency covers the first attempt of every batch, retries not included.
+func (l *CachedLogging[T]) Metrics() Metrics {
+	total := l.Stats().Total()
+
+	return Metrics{
+		Records:      total.Records,
+		Bytes:        total.Bytes,
+		Errors:       total.FailedWrites,
+		Dropped:      total.Dropped,
+		QueueDepth:   total.QueueDepth,
+		FlushLatency: l.latency.snapshot(),
+	}
+}
+
+// expvarMu makes the check and the publishing of a name atomic, expvar
+// panics on a duplicate
+var expvarMu sync.Mutex
+
+// publishExpvar exposes Stats under name in /debug/vars. Names are global to
+// the process and can not be removed, so a name already taken is reported
+// and left alone.
+func (l *CachedLogging[T]) publishExpvar(name string) {
+	expvarMu.Lock()
+	defer expvarMu.Unlock()
+
+	if expvar.Get(name) != nil {
+		l.error.Print(expvarNameTaken, name)
+		return
+	}
+
+	expvar.Publish(name, expvar.Func(func() any {
+		return l.Stats()
+	}))
+}
@@ -0,0 +1,120 @@
+package logger
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"expvar"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/nano-interactive/go-logger/__mocks__/error_log"
+	"github.com/nano-interactive/go-logger/serializer"
+)
+
+func TestCachedLogging_Stats(t *testing.T) {
+	t.Parallel()
+	assert := require.New(t)
+
+	buf := &bytes.Buffer{}
+	inner := New[string](buf, serializer.NewJson[string]())
+	cached := NewCached[string](context.Background(), inner, WithBufferSize(2))
+
+	assert.NoError(cached.LogMultiple([]string{"a", "b", "c"}))
+	assert.NoError(cached.Flush(context.Background()))
+
+	stats := cached.Stats()
+	assert.Len(stats.Workers, 1)
+	assert.Equal(WorkerStats{
+		Worker:   0,
+		Priority: PriorityNormal,
+		Enqueued: 3,
+		Records:  3,
+		Batches:  2,
+		Bytes:    uint64(buf.Len()),
+	}, stats.Workers[0])
+
+	assert.NoError(cached.Close())
+}
+
+func TestCachedLogging_Stats_FailedWrites(t *testing.T) {
+	t.Parallel()
+	assert := require.New(t)
+
+	clock := newFakeClock()
+	inner := &flakyLogger[int]{failures: 2}
+	cached := NewCached[int](context.Background(), inner,
+		WithBufferSize(1),
+		WithRetryCount(3),
+		WithRetryPolicy(RetryPolicy{}),
+		WithClock(clock),
+	)
+
+	assert.NoError(cached.Log(1))
+	assert.NoError(cached.Flush(context.Background()))
+
+	total := cached.Stats().Total()
+	assert.Equal(uint64(1), total.Enqueued)
+	assert.Equal(uint64(1), total.Batches)
+	assert.Equal(uint64(2), total.FailedWrites)
+	assert.Equal(uint64(2), total.Retries)
+	assert.True(clock.Now().Equal(total.LastError))
+
+	assert.NoError(cached.Close())
+}
+
+func TestCachedLogging_Stats_Expvar(t *testing.T) {
+	t.Parallel()
+	assert := require.New(t)
+
+	errLog := error_log.NewMockLogger()
+	inner := &memoryLogger[int]{}
+	cached := NewCached[int](context.Background(), inner, WithExpvar("go-logger-test-stats"))
+
+	assert.NoError(cached.Log(1))
+	assert.NoError(cached.Flush(context.Background()))
+
+	var published Stats
+	assert.NoError(json.Unmarshal([]byte(expvar.Get("go-logger-test-stats").String()), &published))
+	assert.Len(published.Workers, 1)
+	assert.Equal(uint64(1), published.Workers[0].Enqueued)
+
+	// The name is taken, the second logger runs without exporting
+	other := NewCached[int](context.Background(), inner,
+		WithExpvar("go-logger-test-stats"),
+		WithCachedErrorLogger(errLog),
+	)
+	assert.Equal([]string{`{"msg":"expvar name go-logger-test-stats is already published, stats are not exported"}`}, errLog.Buffer)
+
+	assert.NoError(other.Close())
+	assert.NoError(cached.Close())
+}
+
+func TestCachedLogging_Stats_Dropped(t *testing.T) {
+	t.Parallel()
+	assert := require.New(t)
+
+	inner := &memoryLogger[int]{release: make(chan struct{})}
+	cached := NewCached[int](context.Background(), inner,
+		WithBufferSize(1),
+		WithQueueSize(1),
+		WithBackpressure(BackpressureDropNewest),
+	)
+
+	assert.NoError(cached.Log(1))
+	assert.Eventually(func() bool {
+		return cached.lanes[0].workers[0].queue.len() == 0
+	}, time.Second, time.Millisecond)
+	assert.NoError(cached.Log(2))
+	assert.ErrorIs(cached.Log(3), ErrRecordDropped)
+
+	stats := cached.Stats().Workers[0]
+	assert.Equal(1, stats.QueueDepth)
+	assert.Equal(uint64(2), stats.Enqueued)
+	assert.Equal(uint64(1), stats.Dropped)
+
+	close(inner.release)
+	assert.NoError(cached.Close())
+}
@@ -0,0 +1,222 @@
+package logger
+
+import (
+	"strconv"
+	"strings"
+	"time"
+)
+
+// period is the smallest time unit a path template depends on, the file
+// changes every time the clock crosses one.
+type period uint8
+
+const (
+	periodNone period = iota
+	periodYear
+	periodMonth
+	periodDay
+	periodHour
+	periodMinute
+	periodSecond
+)
+
+// TimeRotation switches to a new file every time the wall clock crosses a
+// boundary of the path template, see WithTimeRotation.
+type TimeRotation struct {
+	// Location is the time zone the path is formatted in, time.Local when
+	// nil
+	Location *time.Location
+}
+
+// WithTimeRotation treats the path given to the constructor as a strftime
+// template, /var/log/app/events-%Y%m%d-%H.ndjson writes to a new file every
+// hour. The path is formatted when a batch is written, so a batch that spans
+// a boundary goes whole to the file of the flush time. Missing directories
+// are created. The time comes from WithFileClock.
+//
+// It keeps the handle open, as WithPersistentHandle does, and combines with
+// WithSizeRotation, which then rotates the file of the current period.
+func WithTimeRotation(rotation TimeRotation) FileModifier {
+	return func(c *FileConfig) {
+		if rotation.Location == nil {
+			rotation.Location = time.Local
+		}
+
+		c.template = &rotation
+	}
+}
+
+type templatePart struct {
+	literal string
+	verb    byte
+}
+
+// pathTemplate is a path with strftime directives:
+//
+//	%Y year       %y year without the century
+//	%m month      %d day of the month        %j day of the year
+//	%H hour       %M minute                  %S second
+//	%% a percent sign
+//
+// Any other directive is kept as it is.
+type pathTemplate struct {
+	parts  []templatePart
+	period period
+}
+
+func parsePathTemplate(template string) *pathTemplate {
+	t := &pathTemplate{}
+
+	var literal strings.Builder
+
+	for i := 0; i < len(template); i++ {
+		c := template[i]
+
+		if c != '%' || i+1 == len(template) {
+			literal.WriteByte(c)
+			continue
+		}
+
+		i++
+		verb := template[i]
+		unit := periodNone
+
+		switch verb {
+		case 'Y', 'y':
+			unit = periodYear
+		case 'm':
+			unit = periodMonth
+		case 'd', 'j':
+			unit = periodDay
+		case 'H':
+			unit = periodHour
+		case 'M':
+			unit = periodMinute
+		case 'S':
+			unit = periodSecond
+		case '%':
+			literal.WriteByte('%')
+			continue
+		default:
+			literal.WriteByte('%')
+			literal.WriteByte(verb)
+			continue
+		}
+
+		if literal.Len() > 0 {
+			t.parts = append(t.parts, templatePart{literal: literal.String()})
+			literal.Reset()
+		}
+
+		t.parts = append(t.parts, templatePart{verb: verb})
+
+		if unit > t.period {
+			t.period = unit
+		}
+	}
+
+	if literal.Len() > 0 {
+		t.parts = append(t.parts, templatePart{literal: literal.String()})
+	}
+
+	return t
+}
+
+func (t *pathTemplate) format(now time.Time) string {
+	buf := make([]byte, 0, 64)
+
+	for _, part := range t.parts {
+		switch part.verb {
+		case 0:
+			buf = append(buf, part.literal...)
+		case 'Y':
+			buf = appendInt(buf, now.Year(), 4)
+		case 'y':
+			buf = appendInt(buf, now.Year()%100, 2)
+		case 'm':
+			buf = appendInt(buf, int(now.Month()), 2)
+		case 'd':
+			buf = appendInt(buf, now.Day(), 2)
+		case 'j':
+			buf = appendInt(buf, now.YearDay(), 3)
+		case 'H':
+			buf = appendInt(buf, now.Hour(), 2)
+		case 'M':
+			buf = appendInt(buf, now.Minute(), 2)
+		case 'S':
+			buf = appendInt(buf, now.Second(), 2)
+		}
+	}
+
+	return string(buf)
+}
+
+// bounds returns the start and the end of the period now falls in, in the
+// location of now. The path does not change in between.
+func (t *pathTemplate) bounds(now time.Time) (time.Time, time.Time) {
+	y, mo, d := now.Date()
+	h, mi, s := now.Clock()
+	loc := now.Location()
+
+	switch t.period {
+	case periodYear:
+		return time.Date(y, 1, 1, 0, 0, 0, 0, loc), time.Date(y+1, 1, 1, 0, 0, 0, 0, loc)
+	case periodMonth:
+		return time.Date(y, mo, 1, 0, 0, 0, 0, loc), time.Date(y, mo+1, 1, 0, 0, 0, 0, loc)
+	case periodDay:
+		return time.Date(y, mo, d, 0, 0, 0, 0, loc), time.Date(y, mo, d+1, 0, 0, 0, 0, loc)
+	case periodHour:
+		// Added instead of normalized, a DST change repeats or skips an hour
+		start := time.Date(y, mo, d, h, 0, 0, 0, loc)
+		return start, start.Add(time.Hour)
+	case periodMinute:
+		start := time.Date(y, mo, d, h, mi, 0, 0, loc)
+		return start, start.Add(time.Minute)
+	case periodSecond:
+		start := time.Date(y, mo, d, h, mi, s, 0, loc)
+		return start, start.Add(time.Second)
+	default:
+		// No directive, the path never changes
+		return time.Time{}, time.Unix(1<<62, 0)
+	}
+}
+
+// switchPeriod moves the handle to the file of the current period, the file
+// of the previous one is closed; the caller holds h.mu.
+func (h *fileHandle) switchPeriod() {
+	if h.template == nil {
+		return
+	}
+
+	now := h.clock.Now().In(h.location)
+
+	// Checked both ways, the clock may be set back
+	if !now.Before(h.periodStart) && now.Before(h.periodEnd) {
+		return
+	}
+
+	h.periodStart, h.periodEnd = h.template.bounds(now)
+
+	path := h.template.format(now)
+	if path == h.path {
+		return
+	}
+
+	if h.file != nil {
+		_ = h.closeFile()
+		h.compress(h.path)
+		h.rotated()
+	}
+
+	h.path = path
+}
+
+func appendInt(buf []byte, n, width int) []byte {
+	s := strconv.Itoa(n)
+
+	for i := len(s); i < width; i++ {
+		buf = append(buf, '0')
+	}
+
+	return append(buf, s...)
+}
@@ -0,0 +1,93 @@
+package logger
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	realSerializer "github.com/nano-interactive/go-logger/serializer"
+)
+
+func TestPathTemplate_Format(t *testing.T) {
+	t.Parallel()
+	assert := require.New(t)
+
+	now := time.Date(2022, time.March, 4, 5, 6, 7, 0, time.UTC)
+
+	template := parsePathTemplate("logs/%Y/%y%m%d-%j-%H%M%S-%%H-%q.log")
+	assert.Equal("logs/2022/220304-063-050607-%H-%q.log", template.format(now))
+	assert.Equal(periodSecond, template.period)
+
+	template = parsePathTemplate("logs/events-%Y%m%d-%H.ndjson")
+	start, end := template.bounds(now)
+	assert.Equal(time.Date(2022, time.March, 4, 5, 0, 0, 0, time.UTC), start)
+	assert.Equal(time.Date(2022, time.March, 4, 6, 0, 0, 0, time.UTC), end)
+
+	template = parsePathTemplate("logs/events.ndjson")
+	assert.Equal("logs/events.ndjson", template.format(now))
+	start, end = template.bounds(now)
+	assert.True(start.Before(now) && end.After(now))
+}
+
+func TestFileLogger_TimeRotation(t *testing.T) {
+	t.Parallel()
+	assert := require.New(t)
+
+	dir := t.TempDir()
+	clock := newFakeClock()
+	zone := time.FixedZone("UTC+2", 2*60*60)
+
+	fileLogger := NewFileLoggerWithOptions[logData](filepath.Join(dir, "%Y%m%d", "events-%H.ndjson"), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644, realSerializer.NewJson[logData](),
+		WithTimeRotation(TimeRotation{Location: zone}),
+		WithFileClock(clock),
+	)
+
+	assert.NoError(fileLogger.Log(logData{Name: "a"}))
+
+	clock.Advance(59 * time.Minute)
+	assert.NoError(fileLogger.Log(logData{Name: "b"}))
+
+	// The batch was collected before the boundary, it goes to the file of
+	// the flush time
+	clock.Advance(time.Minute)
+	assert.NoError(fileLogger.LogMultiple([]logData{{Name: "c"}, {Name: "d"}}))
+
+	clock.Advance(22 * time.Hour)
+	assert.NoError(fileLogger.Log(logData{Name: "e"}))
+
+	assert.NoError(fileLogger.Close())
+
+	assert.Equal([]string{`{"name":"a"}`, `{"name":"b"}`}, readLines(t, filepath.Join(dir, "20220101", "events-02.ndjson")))
+	assert.Equal([]string{`{"name":"c"}`, `{"name":"d"}`}, readLines(t, filepath.Join(dir, "20220101", "events-03.ndjson")))
+	assert.Equal([]string{`{"name":"e"}`}, readLines(t, filepath.Join(dir, "20220102", "events-01.ndjson")))
+}
+
+func TestFileLogger_TimeRotation_SizeRotation(t *testing.T) {
+	t.Parallel()
+	assert := require.New(t)
+
+	dir := t.TempDir()
+	clock := newFakeClock()
+
+	fileLogger := NewFileLoggerWithOptions[logData](filepath.Join(dir, "events-%H.ndjson"), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644, realSerializer.NewJson[logData](),
+		WithTimeRotation(TimeRotation{Location: time.UTC}),
+		WithSizeRotation(SizeRotation{MaxSize: 30}),
+		WithFileClock(clock),
+	)
+
+	for _, name := range []string{"a", "b", "c"} {
+		assert.NoError(fileLogger.Log(logData{Name: name}))
+	}
+
+	clock.Advance(time.Hour)
+	assert.NoError(fileLogger.Log(logData{Name: "d"}))
+
+	assert.NoError(fileLogger.Close())
+
+	assert.Equal([]string{`{"name":"c"}`}, readLines(t, filepath.Join(dir, "events-00.ndjson")))
+	assert.Equal([]string{`{"name":"a"}`, `{"name":"b"}`}, readLines(t, filepath.Join(dir, "events-00.ndjson.1")))
+	assert.Equal([]string{`{"name":"d"}`}, readLines(t, filepath.Join(dir, "events-01.ndjson")))
+}
@@ -2,99 +2,358 @@ package logger
 
 import (
 	"context"
+	"runtime"
+	"runtime/debug"
 	"sync"
+	"sync/atomic"
+	"time"
 )
 
-var (
-	retryPool     sync.Pool
-	retryPoolOnce sync.Once
-)
+type logWorker[T any] struct {
+	id            int
+	priority      Priority
+	log           Log[T]
+	error         Error
+	retries       *retryQueue[T]
+	spool         *spool
+	ordered       bool
+	queue         queue[T]
+	flushes       chan chan struct{}
+	limits        chan batchLimit[T]
+	pauses        chan bool
+	clock         Clock
+	limit         batchLimit[T]
+	flushInterval time.Duration
+	restarts      atomic.Uint64
+	stats         workerStats
+	latency       *latencyHistogram
+	pauseLimit    PauseLimit
+	// dropped is CachedLogging.dropped, for the held batches a paused
+	// worker drops
+	dropped *atomic.Uint64
 
-func getRetryPool[T any]() *sync.Pool {
-	retryPoolOnce.Do(func() {
-		retryPool = sync.Pool{
-			New: func() any {
-				return make([]T, 0)
-			},
-		}
-	})
+	// retire stops this worker alone, done is closed once it exited
+	retire chan struct{}
+	done   chan struct{}
+	// producers counts the Log calls pushing to the queue, they picked the
+	// worker under CachedLogging.mu but push without it
+	producers sync.WaitGroup
+
+	// The batch outlives a restart, only the one that panicked is lost.
+	// It holds the records [start, start+len(cache)), received is the
+	// sequence number of the next record, as seen by the spool.
+	cache    []T
+	bytes    int
+	start    uint64
+	received uint64
+	// adding is set while the record being added is sized. When that
+	// panics, only the record is lost and split has the batch before it
+	// written on restart, so the sequence numbers stay contiguous.
+	adding bool
+	split  bool
+	// writingHeld is the size of the held batch being written, which is in
+	// neither the cache nor held anymore
+	writingHeld int
 
-	return &retryPool
+	// scratch receives the records popped in one go, pending are the ones
+	// not added to the batch yet. They survive a restart.
+	scratch []T
+	pending []T
+
+	// A paused worker holds the full batches instead of writing them
+	paused      bool
+	held        []heldBatch[T]
+	heldRecords int
+	heldBytes   int
 }
 
-func logWorker[T any](ctx context.Context, wg *sync.WaitGroup, log Log[T], ch <-chan T, bufferSize, retryCount int) {
-	cache := make([]T, bufferSize, bufferSize)
-	idx := 0
+// receiveBatch is the most records a worker pops from its queue at once
+const receiveBatch = 256
+
+// supervise runs the worker and restarts it on the same channel whenever
+// the inner logger, the serializer or a callback panics.
+func (w *logWorker[T]) supervise(ctx context.Context, wg *sync.WaitGroup) {
 	defer wg.Done()
+	defer close(w.done)
 
-	reset := func() {
-		idx = 0
+	capacity := w.limit.count
+	if capacity <= 0 {
+		capacity = defaultBatchCapacity
 	}
 
-	set := func(data T) {
-		cache[idx] = data
-		idx++
+	w.cache = make([]T, 0, capacity)
+	w.scratch = make([]T, receiveBatch)
+
+	for !w.runRecovered(ctx) {
 	}
+}
+
+// runRecovered returns false when the worker panicked and has to restart.
+func (w *logWorker[T]) runRecovered(ctx context.Context) (stopped bool) {
+	defer func() {
+		if r := recover(); r != nil {
+			discarded := w.discard()
+			restarts := w.restarts.Add(1)
+			w.error.Print(workerPanicked, w.id, restarts, discarded, r, debug.Stack())
+		}
+	}()
 
-	retryWrite := func() {
-		var err error
+	w.run(ctx)
+
+	return true
+}
 
-		defer reset()
+// discard drops the batch that was being written when the worker panicked,
+// along with the record being added, or only that record when sizing it
+// panicked. Writing it again would most likely panic again, so the spool
+// forgets about it as well.
+func (w *logWorker[T]) discard() int {
+	if w.adding {
+		w.adding = false
+		w.split = true
+		w.stats.buffered.Add(-1)
 
-		if err = log.LogMultiple(cache); err == nil {
-			return
+		if w.spool != nil {
+			w.spool.ack(w.received-1, w.received)
 		}
 
-		retryQueue := getRetryPool[T]().Get().([]T)
-		copy(retryQueue, cache) // size of the retryQueue is always equal to the size of the cache
+		return 1
+	}
 
-		go func(retryQueue []T) {
-			defer func() {
-				retryQueue = retryQueue[:0]
-				getRetryPool[T]().Put(retryQueue)
-			}()
+	discarded := int(w.received - w.start)
+	w.stats.buffered.Add(-int64(discarded + w.writingHeld))
+	w.writingHeld = 0
 
-			for i := 0; i < retryCount; i++ {
-				if err = log.LogMultiple(retryQueue); err == nil {
-					return
-				}
-			}
-		}(retryQueue)
+	if w.spool != nil && discarded > 0 {
+		w.spool.ack(w.start, w.received)
+	}
+
+	w.reset()
+	w.start = w.received
+
+	return discarded
+}
+
+func (w *logWorker[T]) reset() {
+	// Drop the references so the records can be collected
+	var zero T
+	for i := range w.cache {
+		w.cache[i] = zero
+	}
+
+	w.start += uint64(len(w.cache))
+	w.cache = w.cache[:0]
+	w.bytes = 0
+}
+
+func (w *logWorker[T]) retryWrite() {
+	if w.paused {
+		w.hold()
+		return
+	}
+
+	w.writeRecords(w.cache, w.start)
+	w.reset()
+}
+
+// writeRecords writes the batch whose first record has the spool sequence
+// number start, handing it to the retry queue when it fails.
+func (w *logWorker[T]) writeRecords(batch []T, first uint64) {
+	var done func()
+
+	if w.spool != nil {
+		end := first + uint64(len(batch))
+		done = func() {
+			w.spool.ack(first, end)
+		}
+	}
+
+	// The cache starts at w.start, a held batch before it
+	if first != w.start {
+		w.writingHeld = len(batch)
+	}
+
+	start := w.clock.Now()
+	n, err := writeBatch(w.log, batch)
+	elapsed := w.clock.Now().Sub(start)
+
+	// Delivered, or counted by the retry queue from now on
+	w.writingHeld = 0
+	w.stats.buffered.Add(-int64(len(batch)))
+
+	w.latency.observe(elapsed)
+	w.stats.flushes.Add(1)
+	w.stats.flushNanos.Add(uint64(elapsed))
+	w.stats.written(len(batch), n, err, w.clock)
+
+	if err == nil {
+		if done != nil {
+			done()
+		}
+	} else if w.ordered {
+		// The retry queue copies the batch, the caller is free to reuse it
+		w.retries.retryNow(batch, err, done, &w.stats)
+	} else {
+		w.retries.submit(batch, err, done, &w.stats)
+	}
+}
+
+// add puts the record into the batch. The caller counts it as received
+// first, so a panic here discards it as well.
+func (w *logWorker[T]) add(data T) {
+	w.adding = true
+	n := w.limit.sizeOf(data)
+	w.adding = false
+
+	// Write first when the record would take the batch over the limit
+	if !w.limit.fits(len(w.cache), w.bytes, n) {
+		w.retryWrite()
+	}
+
+	w.cache = append(w.cache, data)
+	w.bytes += n
+
+	if w.limit.full(len(w.cache), w.bytes) {
+		w.retryWrite()
+	}
+}
+
+// receive pops up to max records from the queue into the batch and
+// returns how many it got.
+func (w *logWorker[T]) receive(max int) int {
+	buf := w.scratch
+	if max < len(buf) {
+		buf = buf[:max]
+	}
+
+	n := w.queue.popBatch(buf)
+	w.stats.buffered.Add(int64(n))
+	w.pending = buf[:n]
+	w.addPending()
+
+	return n
+}
+
+func (w *logWorker[T]) addPending() {
+	var zero T
+
+	for len(w.pending) > 0 {
+		data := w.pending[0]
+		w.pending[0] = zero
+		w.pending = w.pending[1:]
+
+		w.received++
+		w.add(data)
+	}
+}
+
+// flush writes everything queued before the request, so records
+// logged concurrently with Flush cannot keep it from finishing
+func (w *logWorker[T]) flush(reply chan<- struct{}) {
+	// Flush must not hang if the write panics
+	defer func() {
+		reply <- struct{}{}
+	}()
+
+	// A drop-oldest producer can empty the queue under us
+	for pending := w.queue.len(); pending > 0; {
+		n := w.receive(pending)
+		if n == 0 {
+			break
+		}
+
+		pending -= n
+	}
+
+	if len(w.cache) > 0 {
+		w.retryWrite()
+	}
+}
+
+// setLimit applies the limit from Reconfigure. A batch already over the
+// new limit is written right away.
+func (w *logWorker[T]) setLimit(limit batchLimit[T]) {
+	w.limit = limit
+
+	if len(w.cache) > 0 && limit.full(len(w.cache), w.bytes) {
+		w.retryWrite()
+	}
+}
+
+func (w *logWorker[T]) run(ctx context.Context) {
+	// A nil channel never fires, so without an interval the worker
+	// only flushes on a full cache and on shutdown
+	var tick <-chan time.Time
+
+	if w.flushInterval > 0 {
+		ticker := w.clock.NewTicker(w.flushInterval)
+		defer ticker.Stop()
+		tick = ticker.C()
 	}
 
+	// The record after the batch panicked, it was dropped alone
+	if w.split {
+		w.split = false
+
+		if len(w.cache) > 0 {
+			w.retryWrite()
+		}
+
+		w.start = w.received
+	}
+
+	// Left over by the run that panicked
+	w.addPending()
+
+	recv := w.queue.recv()
+
 	for {
+		recv, ready := recv, w.queue.ready()
+
+		switch {
+		case w.paused && w.heldFull() && w.pauseLimit.Overflow == PauseOverflowBackpressure:
+			// Leave the records in the queue, the producers feel it
+			recv, ready = nil, nil
+		case !w.queue.park():
+			ready = alwaysReady
+		}
+
 		select {
 		case <-ctx.Done():
 			goto flush
-		case data, more := <-ch:
-			var appended bool
-
-			if idx < bufferSize-1 {
-				set(data)
-				appended = true
-			}
-
-			// Recalculate length again so we can flush the cache
-			if idx == bufferSize-1 {
-				retryWrite()
-				reset()
-			}
-
-			if !appended {
-				set(data)
+		case <-w.retire:
+			goto flush
+		case <-tick:
+			// A paused worker only holds full batches
+			if len(w.cache) > 0 && !w.paused {
+				w.retryWrite()
 			}
-
-			if !more {
-				goto flush
+		case reply := <-w.flushes:
+			w.flush(reply)
+		case limit := <-w.limits:
+			w.setLimit(limit)
+		case paused := <-w.pauses:
+			w.setPaused(paused)
+		case data := <-recv:
+			w.received++
+			w.stats.buffered.Add(1)
+			w.add(data)
+		case <-ready:
+			// A producer is between claiming a slot and filling it
+			if w.receive(receiveBatch) == 0 {
+				runtime.Gosched()
 			}
 		}
 	}
 flush:
-	// Empty the buffered channel
-	cache = cache[:idx]
-	for data := range ch {
-		cache = append(cache, data)
+	// Shutdown and retiring write everything, paused or not
+	w.setPaused(false)
+
+	// Empty the queue, no producer routes to this worker anymore
+	for w.receive(receiveBatch) > 0 {
 	}
 
-	retryWrite()
+	if len(w.cache) > 0 {
+		w.retryWrite()
+	}
 }
@@ -1,5 +1,230 @@
 package logger
 
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/nano-interactive/go-logger/__mocks__/error_log"
+	"github.com/nano-interactive/go-logger/serializer"
+)
+
+func TestLogWorker_FlushInterval(t *testing.T) {
+	t.Parallel()
+	assert := require.New(t)
+
+	clock := newFakeClock()
+	inner := &memoryLogger[int]{}
+	cached := NewCached[int](context.Background(), inner,
+		WithBufferSize(100),
+		WithWorkerPool(2),
+		WithFlushInterval(time.Second),
+		WithClock(clock),
+	)
+
+	clock.WaitForTickers(2)
+
+	assert.NoError(cached.LogMultiple([]int{1, 2, 3}))
+
+	// Neither the buffer is full nor did the clock move
+	assert.Empty(inner.records())
+
+	assert.Eventually(func() bool {
+		clock.Advance(time.Second)
+		return len(inner.records()) == 3
+	}, time.Second, time.Millisecond)
+
+	assert.ElementsMatch([]int{1, 2, 3}, inner.records())
+	assert.NoError(cached.Close())
+}
+
+func TestLogWorker_FlushInterval_Disabled(t *testing.T) {
+	t.Parallel()
+	assert := require.New(t)
+
+	clock := newFakeClock()
+	inner := &memoryLogger[int]{}
+	cached := NewCached[int](context.Background(), inner, WithBufferSize(100), WithClock(clock))
+
+	assert.NoError(cached.Log(1))
+	clock.Advance(time.Hour)
+
+	assert.Empty(clock.tickers)
+	assert.Empty(inner.records())
+
+	assert.NoError(cached.Close())
+	assert.Equal([]int{1}, inner.records())
+}
+
+func (m *memoryLogger[T]) batchesCopy() [][]T {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return append([][]T(nil), m.batches...)
+}
+
+func TestLogWorker_MaxBatchBytes(t *testing.T) {
+	t.Parallel()
+	assert := require.New(t)
+
+	inner := &memoryLogger[string]{}
+	cached := NewCached[string](context.Background(), inner,
+		WithMaxBatchBytes(10, func(s string) int { return len(s) }),
+	)
+
+	// The third record would take the batch to 12 bytes, the oversized one
+	// goes out alone
+	assert.NoError(cached.LogMultiple([]string{"aaaa", "bbbb", "cccc", "dddddddddddddddd", "ee"}))
+	assert.NoError(cached.Close())
+
+	assert.Equal([][]string{
+		{"aaaa", "bbbb"},
+		{"cccc"},
+		{"dddddddddddddddd"},
+		{"ee"},
+	}, inner.batchesCopy())
+}
+
+func TestLogWorker_MaxBatchBytes_WithBufferSize(t *testing.T) {
+	t.Parallel()
+	assert := require.New(t)
+
+	inner := &memoryLogger[string]{}
+	cached := NewCached[string](context.Background(), inner,
+		WithBufferSize(2),
+		WithMaxBatchBytes(10, func(s string) int { return len(s) }),
+	)
+
+	assert.NoError(cached.LogMultiple([]string{"a", "b", "c", "dddddddd", "eeeeeeee"}))
+	assert.NoError(cached.Close())
+
+	assert.Equal([][]string{
+		{"a", "b"},
+		{"c", "dddddddd"},
+		{"eeeeeeee"},
+	}, inner.batchesCopy())
+}
+
+func TestLogWorker_MaxBatchBytes_ZeroSize(t *testing.T) {
+	t.Parallel()
+	assert := require.New(t)
+
+	inner := &memoryLogger[string]{}
+	cached := NewCached[string](context.Background(), inner,
+		WithMaxBatchBytes(2, func(s string) int { return 0 }),
+	)
+
+	// Every record is one byte at least
+	assert.NoError(cached.LogMultiple([]string{"a", "b", "c"}))
+	assert.NoError(cached.Close())
+
+	assert.Equal([][]string{{"a", "b"}, {"c"}}, inner.batchesCopy())
+}
+
+func TestLogWorker_MaxBatchBytes_WrongType(t *testing.T) {
+	t.Parallel()
+	assert := require.New(t)
+
+	inner := &memoryLogger[string]{}
+	cached := NewCached[string](context.Background(), inner,
+		WithMaxBatchBytes(10, func(n int) int { return n }),
+	)
+
+	// The byte limit is ignored, the default record count bounds the batch
+	assert.Equal(defaultBufferSize, cached.limit.count)
+
+	records := make([]string, defaultBufferSize+1)
+	assert.NoError(cached.LogMultiple(records))
+	assert.Eventually(func() bool {
+		return len(inner.records()) == defaultBufferSize
+	}, time.Second, time.Millisecond)
+
+	assert.NoError(cached.Close())
+}
+
+func TestSerializedSize(t *testing.T) {
+	t.Parallel()
+	assert := require.New(t)
+
+	size := SerializedSize[string](serializer.NewJson[string]())
+
+	assert.Equal(len("\"abc\"\n"), size("abc"))
+}
+
+// panickingLogger panics on every batch that holds the record "boom".
+type panickingLogger struct {
+	memoryLogger[string]
+}
+
+func (l *panickingLogger) LogMultiple(data []string) error {
+	for _, record := range data {
+		if record == "boom" {
+			panic("inner logger failed")
+		}
+	}
+
+	return l.memoryLogger.LogMultiple(data)
+}
+
+func TestLogWorker_PanicRestartsWorker(t *testing.T) {
+	t.Parallel()
+	assert := require.New(t)
+
+	errLog := error_log.NewMockLogger()
+	inner := &panickingLogger{}
+	cached := NewCached[string](context.Background(), inner,
+		WithBufferSize(1),
+		WithCachedErrorLogger(errLog),
+	)
+
+	assert.NoError(cached.LogMultiple([]string{"a", "boom", "b"}))
+	assert.NoError(cached.Flush(context.Background()))
+
+	// The same channel is drained by the restarted worker
+	assert.NoError(cached.Log("boom"))
+	assert.NoError(cached.Flush(context.Background()))
+	assert.NoError(cached.Log("c"))
+	assert.NoError(cached.Close())
+
+	assert.Equal([]string{"a", "b", "c"}, inner.records())
+	assert.Equal(uint64(2), cached.Restarts())
+	assert.Len(errLog.Buffer, 2)
+	assert.Contains(errLog.Buffer[0], `"msg":"worker panicked and was restarted","worker":0,"restarts":1,"discarded":1,"panic":"inner logger failed"`)
+	assert.Contains(errLog.Buffer[0], "runtime/debug.Stack")
+}
+
+func TestLogWorker_PanicInSizeKeepsBatch(t *testing.T) {
+	t.Parallel()
+	assert := require.New(t)
+
+	errLog := error_log.NewMockLogger()
+	inner := &memoryLogger[int]{}
+	cached := NewCached[int](context.Background(), inner,
+		WithBufferSize(100),
+		WithMaxBatchBytes[int](1<<20, func(i int) int {
+			if i == 5 {
+				panic("size failed")
+			}
+			return 1
+		}),
+		WithCachedErrorLogger(errLog),
+	)
+
+	for i := 0; i < 10; i++ {
+		assert.NoError(cached.Log(i))
+	}
+
+	assert.NoError(cached.Close())
+
+	// Only the record that panicked is lost, the batch before it is
+	// written on its own
+	assert.Equal([][]int{{0, 1, 2, 3, 4}, {6, 7, 8, 9}}, inner.batches)
+	assert.Len(errLog.Buffer, 1)
+	assert.Contains(errLog.Buffer[0], `"discarded":1,"panic":"size failed"`)
+}
+
 // func TestLogWorker(t *testing.T) {
 // 	t.Parallel()
 
@@ -17,6 +17,8 @@ type SignalReopen struct {
 	signal os.Signal
 	reopen func() io.WriteCloser
 	cancel context.CancelFunc
+	// reopened runs once the old handle is closed
+	reopened atomic.Pointer[func()]
 }
 
 func NewSignalReopen(w io.WriteCloser, s os.Signal, reopen func() io.WriteCloser, errCh ...chan<- error) *SignalReopen {
@@ -48,6 +50,10 @@ func NewSignalReopen(w io.WriteCloser, s os.Signal, reopen func() io.WriteCloser
 				newHandle := reopen()
 				old := writer.handle.Swap(&newHandle)
 				closeFile(*old)
+
+				if fn := writer.reopened.Load(); fn != nil {
+					(*fn)()
+				}
 			}
 		}
 	}()
@@ -55,12 +61,30 @@ func NewSignalReopen(w io.WriteCloser, s os.Signal, reopen func() io.WriteCloser
 	return writer
 }
 
+// OnReopened sets a callback that runs after every reopen, once the old
+// handle is closed and nothing writes to the moved file anymore, for
+// example to compress it.
+func (w *SignalReopen) OnReopened(fn func()) {
+	w.reopened.Store(&fn)
+}
+
 func (w *SignalReopen) Write(data []byte) (int, error) {
 	handle := w.handle.Load()
 
 	return (*handle).Write(data)
 }
 
+// Sync commits the current handle to stable storage, when it supports it.
+func (w *SignalReopen) Sync() error {
+	handle := w.handle.Load()
+
+	if s, ok := (*handle).(interface{ Sync() error }); ok {
+		return s.Sync()
+	}
+
+	return nil
+}
+
 func (w *SignalReopen) Close() error {
 	c := w.cancel
 
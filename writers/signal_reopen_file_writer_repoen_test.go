@@ -56,10 +56,14 @@ func TestNewSignalReopen_ReplaceWriter(t *testing.T) {
 
 	assert.NotNil(buffer)
 
+	reopened := make(chan struct{}, 1)
+	buffer.OnReopened(func() { reopened <- struct{}{} })
+
 	p, _ := os.FindProcess(os.Getpid())
 
 	assert.NoError(p.Signal(os.Interrupt))
 	assert.Nil(<-errCh)
+	<-reopened
 	assert.NotNil(buffer)
 	assert.Equal(replaceWriter, (*buffer.handle.Load()).(*writer.MockWriteCloser))
 	mockWriter.AssertExpectations(t)
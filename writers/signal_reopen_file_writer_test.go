@@ -2,6 +2,7 @@ package writers
 
 import (
 	"io"
+	"os"
 	"syscall"
 	"testing"
 
@@ -53,3 +54,29 @@ func TestSignalReopenWriter_Write(t *testing.T) {
 
 	mockWriter.AssertExpectations(t)
 }
+
+func TestSignalReopenWriter_Sync(t *testing.T) {
+	t.Parallel()
+	assert := require.New(t)
+
+	file, err := os.CreateTemp(t.TempDir(), "sync")
+	assert.NoError(err)
+
+	buffer := NewSignalReopen(file, syscall.SIGHUP, func() io.WriteCloser {
+		return &writer.MockWriteCloser{}
+	})
+
+	assert.NoError(buffer.Sync())
+	assert.NoError(buffer.Close())
+
+	// Writers without Sync are a no-op
+	mockWriter := &writer.MockWriteCloser{}
+	mockWriter.On("Close").Return(nil)
+
+	buffer = NewSignalReopen(mockWriter, syscall.SIGHUP, func() io.WriteCloser {
+		return &writer.MockWriteCloser{}
+	})
+
+	assert.NoError(buffer.Sync())
+	assert.NoError(buffer.Close())
+}